@@ -0,0 +1,392 @@
+package rtcstats
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"rtcstats/internal/processor"
+	"rtcstats/internal/sampling"
+)
+
+// BatchReportFile is the name of the report ProcessDir writes to its
+// output directory.
+const BatchReportFile = "report.json"
+
+// BatchFile reports on one input of ProcessDir.
+type BatchFile struct {
+	Input   string
+	Output  string
+	Size    int64     // input size when it was processed
+	ModTime time.Time // input modification time when it was processed
+	Options string    // digest of the options it was processed with
+
+	// Skipped is true when an earlier run already processed the input;
+	// Result is then the one from that run.
+	Skipped bool
+	Error   string // why processing failed, if it did
+	Result  *Result
+}
+
+// BatchResult is the aggregate report of ProcessDir. The totals cover
+// every input with a Result, including skipped ones.
+type BatchResult struct {
+	Files     []BatchFile
+	Processed int
+	Skipped   int
+	Failed    int
+
+	InputBytes   int64
+	OutputBytes  int64
+	Reduction    float64 // 0–1 fraction
+	InputTokens  int
+	OutputTokens int
+}
+
+// ProcessDir processes every file matched by pattern, a directory or a
+// glob, into outDir. Each output keeps its input's file name. Inputs are
+// processed in parallel by a bounded pool of workers (see WithWorkers),
+// each with its own pipeline. Failures are recorded per file and do not
+// stop the batch.
+//
+// The report is written to outDir/BatchReportFile and returned. An input
+// whose entry in the previous report succeeded, whose size and
+// modification time are unchanged, that was processed with the same
+// options and whose output still exists is skipped, unless WithReprocess
+// is set. A run that is interrupted before writing its report is
+// therefore repeated in full.
+//
+// Custom encoders, handlers, tokenizers and event filters are functions,
+// so only their number is compared: set WithReprocess after changing one.
+// Filters given with WithEventSelector are compared in full.
+func ProcessDir(pattern, outDir string, opts ...Option) (*BatchResult, error) {
+	cfg := applyOpts(opts)
+	if err := checkOptions(cfg); err != nil {
+		return nil, err
+	}
+
+	inputs, err := batchInputs(pattern)
+	if err != nil {
+		return nil, err
+	}
+	files, err := batchFiles(inputs, outDir, optionsDigest(cfg))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating output directory: %w", err)
+	}
+
+	reportPath := filepath.Join(outDir, BatchReportFile)
+	var prev map[string]BatchFile
+	if !cfg.reprocess {
+		prev = readBatchReport(reportPath)
+	}
+
+	logConfig(cfg)
+
+	workers := cfg.workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	jobs := make(chan *BatchFile)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range jobs {
+				processBatchFile(f, prev[f.Input], cfg)
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- &files[i]
+	}
+	close(jobs)
+	wg.Wait()
+
+	res := summarizeBatch(files)
+	if err := writeBatchReport(reportPath, res); err != nil {
+		return nil, err
+	}
+	logBatch(cfg.logger, res, reportPath)
+	return res, nil
+}
+
+// batchInputs returns the regular files in directory pattern, or matched
+// by glob pattern, sorted. Hidden files in a directory are left out.
+func batchInputs(pattern string) ([]string, error) {
+	var paths []string
+	if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+		entries, err := os.ReadDir(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("reading input directory: %w", err)
+		}
+		for _, e := range entries {
+			if !strings.HasPrefix(e.Name(), ".") {
+				paths = append(paths, filepath.Join(pattern, e.Name()))
+			}
+		}
+	} else {
+		paths, err = filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("matching inputs: %w", err)
+		}
+	}
+
+	var inputs []string
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil && info.Mode().IsRegular() {
+			inputs = append(inputs, p)
+		}
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no input files match %s", pattern)
+	}
+	sort.Strings(inputs)
+	return inputs, nil
+}
+
+// batchFiles pairs each input with its output in outDir, to be processed
+// with the options of digest. Two inputs with the same name, or an output
+// that would overwrite its input, are errors.
+func batchFiles(inputs []string, outDir, digest string) ([]BatchFile, error) {
+	files := make([]BatchFile, len(inputs))
+	byOutput := make(map[string]string, len(inputs))
+	for i, in := range inputs {
+		out := filepath.Join(outDir, filepath.Base(in))
+		if other, ok := byOutput[out]; ok {
+			return nil, fmt.Errorf("inputs %s and %s would both be written to %s", other, in, out)
+		}
+		byOutput[out] = in
+		if sameFile(in, out) {
+			return nil, fmt.Errorf("output %s would overwrite its input", out)
+		}
+		files[i] = BatchFile{Input: in, Output: out, Options: digest}
+	}
+	return files, nil
+}
+
+// sameFile reports whether a and b name the same existing file.
+func sameFile(a, b string) bool {
+	ia, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	ib, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(ia, ib)
+}
+
+// processBatchFile processes f, unless prev shows an earlier run already did.
+func processBatchFile(f *BatchFile, prev BatchFile, cfg options) {
+	info, err := os.Stat(f.Input)
+	if err != nil {
+		f.Error = fmt.Sprintf("reading input: %v", err)
+		logBatchFile(cfg.logger, f)
+		return
+	}
+	f.Size = info.Size()
+	f.ModTime = info.ModTime()
+
+	if upToDate(*f, prev) {
+		f.Skipped = true
+		f.Result = prev.Result
+		logBatchFile(cfg.logger, f)
+		return
+	}
+
+	res, written, err := processFile(f.Input, f.Output, cfg)
+	if err != nil {
+		f.Error = err.Error()
+		// Do not leave partial output behind, including chunk files
+		for _, path := range written {
+			os.Remove(path)
+		}
+	}
+	f.Result = res
+	logBatchFile(cfg.logger, f)
+}
+
+// upToDate reports whether prev is a successful run over the same input
+// with the same options that wrote the same output, and that output still
+// exists.
+func upToDate(f, prev BatchFile) bool {
+	if prev.Result == nil || prev.Error != "" || prev.Output != f.Output ||
+		prev.Size != f.Size || !prev.ModTime.Equal(f.ModTime) || prev.Options != f.Options {
+		return false
+	}
+	outputs := []string{prev.Output}
+	if len(prev.Result.Chunks) > 0 {
+		outputs = outputs[:0]
+		for _, c := range prev.Result.Chunks {
+			outputs = append(outputs, c.Path)
+		}
+	}
+	for _, out := range outputs {
+		if _, err := os.Stat(out); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// optionsDigest returns a digest of the options that shape an output. It
+// is a hash so that the report does not hold the pseudonym salt.
+func optionsDigest(cfg options) string {
+	key := struct {
+		TSMode        TimestampMode
+		Format        OutputFormat
+		Encoder       bool
+		Sampling      *sampling.Config
+		Lenient       bool
+		StatsDelta    StatsDeltaMode
+		ReorderWindow time.Duration
+		Tabular       bool
+		Tokenizer     string
+		TokenBudget   int
+		ChunkTokens   int
+		Legend        bool
+		Correlate     bool
+		Collapse      *processor.Collapsing
+		Pseudonymize  bool
+		PseudonymSalt string
+		Selectors     []EventSelector
+		Filters       int
+		Handlers      int
+	}{
+		TSMode:        cfg.tsMode,
+		Format:        cfg.format,
+		Encoder:       cfg.encoder != nil,
+		Sampling:      cfg.sampling,
+		Lenient:       cfg.lenient,
+		StatsDelta:    cfg.statsDelta,
+		ReorderWindow: cfg.reorderWindow,
+		Tabular:       cfg.tabular,
+		Tokenizer:     fmt.Sprintf("%T", cfg.tokenizer),
+		TokenBudget:   cfg.tokenBudget,
+		ChunkTokens:   cfg.chunkTokens,
+		Legend:        cfg.legend,
+		Correlate:     cfg.correlate,
+		Collapse:      cfg.collapse,
+		Pseudonymize:  cfg.pseudonymize,
+		PseudonymSalt: cfg.pseudonymSalt,
+		Selectors:     cfg.selectors,
+		Filters:       len(cfg.filters),
+		Handlers:      len(cfg.handlers),
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// summarizeBatch counts the outcomes of files and totals their results.
+func summarizeBatch(files []BatchFile) *BatchResult {
+	res := &BatchResult{Files: files}
+	for _, f := range files {
+		switch {
+		case f.Error != "":
+			res.Failed++
+		case f.Skipped:
+			res.Skipped++
+		default:
+			res.Processed++
+		}
+		if r := f.Result; r != nil {
+			res.InputBytes += r.InputBytes
+			res.OutputBytes += r.OutputBytes
+			res.InputTokens += r.InputTokens
+			res.OutputTokens += r.OutputTokens
+		}
+	}
+	if res.InputBytes > 0 {
+		res.Reduction = 1 - float64(res.OutputBytes)/float64(res.InputBytes)
+	}
+	return res
+}
+
+// readBatchReport returns the entries of the report at path by input. A
+// missing or unreadable report has none.
+func readBatchReport(path string) map[string]BatchFile {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var report BatchResult
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil
+	}
+	prev := make(map[string]BatchFile, len(report.Files))
+	for _, f := range report.Files {
+		prev[f.Input] = f
+	}
+	return prev
+}
+
+// writeBatchReport writes res to path, replacing any earlier report only
+// once the new one is complete.
+func writeBatchReport(path string, res *BatchResult) error {
+	data, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("writing report: %w", err)
+	}
+	return nil
+}
+
+// logBatchFile logs the outcome of one input on a single line, as workers
+// log concurrently.
+func logBatchFile(l Logger, f *BatchFile) {
+	if l == nil {
+		return
+	}
+	switch {
+	case f.Error != "":
+		l.Printf("%s: failed: %s", f.Input, f.Error)
+	case f.Skipped:
+		l.Printf("%s: already processed, skipped", f.Input)
+	default:
+		r := f.Result
+		l.Printf("%s -> %s: %s -> %s (%.1f%% reduction, %d events, ~%s -> ~%s tokens)",
+			f.Input, f.Output, humanBytes(r.InputBytes), humanBytes(r.OutputBytes),
+			r.Reduction*100, r.EventCount, humanCount(r.InputTokens), humanCount(r.OutputTokens))
+	}
+}
+
+func logBatch(l Logger, res *BatchResult, reportPath string) {
+	if l == nil {
+		return
+	}
+	l.Printf("%d files: %d processed, %d skipped, %d failed; %s -> %s (%.1f%% reduction), ~%s -> ~%s tokens; report: %s",
+		len(res.Files), res.Processed, res.Skipped, res.Failed,
+		humanBytes(res.InputBytes), humanBytes(res.OutputBytes), res.Reduction*100,
+		humanCount(res.InputTokens), humanCount(res.OutputTokens), reportPath)
+}
@@ -0,0 +1,94 @@
+package rtcstats_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"rtcstats"
+)
+
+func TestProcessDirSkipsAndCleansUp(t *testing.T) {
+	in, out := t.TempDir(), t.TempDir()
+	good := call(false)
+	// bad fails only after most of its output, and its chunks, are written
+	bad := append(call(false), "[\"getstats\", \"0-pub\", {,\n"...)
+	for name, data := range map[string][]byte{"a.jsonl": good, "b.jsonl": good, "bad.jsonl": bad} {
+		if err := os.WriteFile(filepath.Join(in, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sampled := []rtcstats.Option{rtcstats.WithSampling()}
+	noICE := append(sampled, rtcstats.WithEventSelector(rtcstats.EventSelector{Exclude: []string{"onicecandidate"}}))
+	noState := append(sampled, rtcstats.WithEventSelector(rtcstats.EventSelector{Exclude: []string{"connectionstatechange"}}))
+	chunked := []rtcstats.Option{rtcstats.WithChunking(500)}
+
+	// The steps run in order over the same directories
+	tests := []struct {
+		name      string
+		opts      []rtcstats.Option
+		before    func(t *testing.T)
+		processed int
+		skipped   int
+	}{
+		{name: "first run", processed: 2},
+		{name: "unchanged", skipped: 2},
+		{name: "changed options", opts: sampled, processed: 2},
+		{name: "same options again", opts: sampled, skipped: 2},
+		{name: "added selector", opts: noICE, processed: 2},
+		{name: "other selector", opts: noState, processed: 2},
+		{
+			name: "modified input", opts: noState, processed: 1, skipped: 1,
+			before: func(t *testing.T) {
+				later := time.Now().Add(time.Hour)
+				if err := os.Chtimes(filepath.Join(in, "a.jsonl"), later, later); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "removed output", opts: noState, processed: 1, skipped: 1,
+			before: func(t *testing.T) {
+				if err := os.Remove(filepath.Join(out, "b.jsonl")); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{name: "reprocess", opts: append(noState, rtcstats.WithReprocess()), processed: 2},
+		{name: "chunked", opts: chunked, processed: 2},
+		{name: "chunked again", opts: chunked, skipped: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.before != nil {
+				tt.before(t)
+			}
+			res, err := rtcstats.ProcessDir(in, out, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Processed != tt.processed || res.Skipped != tt.skipped || res.Failed != 1 {
+				t.Errorf("processed, skipped, failed = %d, %d, %d, want %d, %d, 1",
+					res.Processed, res.Skipped, res.Failed, tt.processed, tt.skipped)
+			}
+
+			entries, err := os.ReadDir(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var outputs []string
+			for _, e := range entries {
+				if strings.HasPrefix(e.Name(), "bad") {
+					t.Errorf("output %s of the failed input was left behind", e.Name())
+				}
+				outputs = append(outputs, e.Name())
+			}
+			if len(outputs) < 3 {
+				t.Errorf("outputs = %v, want a, b and %s", outputs, rtcstats.BatchReportFile)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package rtcstats
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"rtcstats/internal/sampling"
+)
+
+// SamplingConfig holds adaptive sampling settings.
+type SamplingConfig = sampling.Config
+
+// BudgetResult reports the settings chosen by WithTokenBudget.
+type BudgetResult struct {
+	Tokens   int             // the requested budget
+	Fits     bool            // false if even the coarsest settings exceed it
+	Sampling *SamplingConfig // chosen settings; nil when no sampling was needed
+	Trials   int             // processing passes used by the search
+}
+
+// budgetLadder lists sampling settings from highest to lowest fidelity.
+// Interval 1 keeps every sample and only applies steady-state suppression,
+// which is lossless. Interesting moments are always kept by the sampler,
+// so even the last rung keeps them at full resolution.
+func budgetLadder() []*SamplingConfig {
+	var ladder []*SamplingConfig
+	for _, interval := range []int{1, 2, 3, 5, 10, 20, 30, 60, 120} {
+		cfg := sampling.DefaultConfig()
+		cfg.Interval = interval
+		ladder = append(ladder, &cfg)
+	}
+	for _, ctx := range []int{1, 0} {
+		cfg := sampling.DefaultConfig()
+		cfg.Interval = 120
+		cfg.ContextBefore, cfg.ContextAfter = ctx, ctx
+		ladder = append(ladder, &cfg)
+	}
+	return ladder
+}
+
+// runWithBudget searches for the highest-fidelity sampling settings whose
+// output fits cfg.tokenBudget and writes that output to w. pass runs one
+// full processing pass. Each pass is buffered so the fitting one can be
+// written without processing the input again.
+//
+// Unsampled output is tried first, then the ladder is binary searched,
+// assuming output size shrinks as the interval grows. If nothing fits, the
+// coarsest settings are used and Fits is false.
+func runWithBudget(w io.Writer, cfg options, pass func(w io.Writer, cfg options) (*Result, error)) (*Result, error) {
+	budget := cfg.tokenBudget
+	cfg.tokenBudget = 0
+
+	type attempt struct {
+		res      *Result
+		out      []byte
+		sampling *SamplingConfig
+	}
+	trials := 0
+	try := func(s *SamplingConfig) (*attempt, error) {
+		c := cfg
+		c.sampling = s
+		trials++
+		var buf bytes.Buffer
+		res, err := pass(&buf, c)
+		if err != nil {
+			return nil, err
+		}
+		return &attempt{res: res, out: buf.Bytes(), sampling: s}, nil
+	}
+
+	chosen, err := try(nil)
+	if err != nil {
+		return nil, err
+	}
+	fits := chosen.res.OutputTokens <= budget
+
+	if !fits {
+		ladder := budgetLadder()
+		var best *attempt
+		lo, hi := 0, len(ladder) // first fitting rung is in [lo, hi]
+		for lo < hi {
+			mid := (lo + hi) / 2
+			a, err := try(ladder[mid])
+			if err != nil {
+				return nil, err
+			}
+			if a.res.OutputTokens <= budget {
+				best, hi = a, mid
+			} else {
+				// When nothing fits, the last rung tried is the coarsest
+				chosen, lo = a, mid+1
+			}
+		}
+		if best != nil {
+			chosen, fits = best, true
+		}
+	}
+
+	if _, err := w.Write(chosen.out); err != nil {
+		return nil, fmt.Errorf("writing output: %w", err)
+	}
+	chosen.res.Budget = &BudgetResult{
+		Tokens:   budget,
+		Fits:     fits,
+		Sampling: chosen.sampling,
+		Trials:   trials,
+	}
+	return chosen.res, nil
+}
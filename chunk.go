@@ -0,0 +1,114 @@
+package rtcstats
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"rtcstats/internal/processor"
+)
+
+// ChunkEvent is the name of the header record that starts every chunk
+// written with WithChunking.
+const ChunkEvent = processor.ChunkEvent
+
+// Chunk describes one chunk written with WithChunking.
+type Chunk struct {
+	Path   string // output file; empty when chunks share one stream
+	Tokens int
+}
+
+// chunkOutput is the destination of chunked output. Given a path, it
+// writes each chunk to its own numbered file; otherwise every chunk goes
+// to w in turn.
+type chunkOutput struct {
+	w     io.Writer
+	path  string
+	file  *output
+	paths []string
+}
+
+// asChunkOutput returns w as a chunkOutput, wrapping it unless it is one
+// (a numbered-file output from ProcessStats).
+func asChunkOutput(w io.Writer) *chunkOutput {
+	if c, ok := w.(*chunkOutput); ok {
+		return c
+	}
+	return &chunkOutput{w: w}
+}
+
+func (c *chunkOutput) Write(p []byte) (int, error) {
+	return c.w.Write(p)
+}
+
+// start opens the output of chunk index, closing the previous chunk's file.
+func (c *chunkOutput) start(index int) error {
+	if c.path == "" {
+		return nil
+	}
+	if err := c.Close(); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	path := chunkPath(c.path, index)
+	out, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+	c.file, c.w = out, out
+	c.paths = append(c.paths, path)
+	return nil
+}
+
+// Close closes the current chunk's file, if any.
+func (c *chunkOutput) Close() error {
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
+
+// chunkPath numbers path for chunk index, keeping the format and
+// compression extensions last: call.jsonl.gz → call.001.jsonl.gz.
+func chunkPath(path string, index int) string {
+	compExt := ""
+	if ext := filepath.Ext(path); ext == ".gz" || ext == ".zst" {
+		compExt = ext
+		path = strings.TrimSuffix(path, ext)
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s.%03d%s%s", strings.TrimSuffix(path, ext), index, ext, compExt)
+}
+
+// newChunking configures the pipeline to split output into chunks of at
+// most cfg.chunkTokens tokens, measured on records encoded by newEnc.
+func newChunking(cfg options, newEnc func(w io.Writer) Encoder, out *chunkOutput) *processor.Chunking {
+	var buf bytes.Buffer
+	enc := newEnc(&buf)
+	return &processor.Chunking{
+		Limit: cfg.chunkTokens,
+		Size: func(ce CompressedEvent) int {
+			buf.Reset()
+			if err := enc.Encode(ce); err != nil {
+				return 0
+			}
+			return cfg.tokenizer.Count(buf.Bytes())
+		},
+		Start: out.start,
+	}
+}
+
+// chunkResults pairs the chunk sizes of a pipeline with their paths.
+func chunkResults(sizes []int, out *chunkOutput) []Chunk {
+	chunks := make([]Chunk, len(sizes))
+	for i, n := range sizes {
+		chunks[i].Tokens = n
+		if i < len(out.paths) {
+			chunks[i].Path = out.paths[i]
+		}
+	}
+	return chunks
+}
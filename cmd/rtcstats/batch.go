@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"rtcstats"
+)
+
+// runBatch handles "rtcstats batch": process every dump in a directory.
+func runBatch(args []string) {
+	fs := flag.NewFlagSet("rtcstats batch", flag.ExitOnError)
+	flags := registerProcessFlags(fs)
+	workers := fs.Int("j", 0, "Number of files to process at once (default: number of CPUs)")
+	force := fs.Bool("force", false, "Reprocess inputs that an earlier run already processed")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: rtcstats batch [flags] -o <output-dir> <input-dir|glob>\n\n")
+		fmt.Fprintf(os.Stderr, "Processes every file in a directory (or matching a glob) into the output\n")
+		fmt.Fprintf(os.Stderr, "directory, in parallel, and writes %s there. Inputs processed by an\n", rtcstats.BatchReportFile)
+		fmt.Fprintf(os.Stderr, "earlier run are skipped unless they changed.\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || flags.outPath() == "" {
+		fmt.Fprintf(os.Stderr, "Error: an input directory or glob and -o <output-dir> are required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	opts := flags.options()
+	if *workers > 0 {
+		opts = append(opts, rtcstats.WithWorkers(*workers))
+	}
+	if *force {
+		opts = append(opts, rtcstats.WithReprocess())
+	}
+
+	res, err := rtcstats.ProcessDir(fs.Arg(0), flags.outPath(), opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *flags.pseudoOut != "" {
+		if err := writePseudonymTables(*flags.pseudoOut, res.Files); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *flags.lenient && !flags.isQuiet() {
+		for _, f := range res.Files {
+			if f.Result != nil && !f.Skipped && len(f.Result.SkippedRecords) > 0 {
+				fmt.Fprintf(os.Stderr, "%s: ", f.Input)
+				printSkipped(f.Result.SkippedRecords)
+			}
+		}
+	}
+	if res.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// writePseudonymTables writes the alias table of each input processed in
+// this run to dir/<input name>.json. Skipped inputs keep the table an
+// earlier run wrote, as the report does not hold it.
+func writePseudonymTables(dir string, files []rtcstats.BatchFile) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("writing pseudonym table: %w", err)
+	}
+	for _, f := range files {
+		if f.Skipped || f.Result == nil {
+			continue
+		}
+		path := filepath.Join(dir, filepath.Base(f.Input)+".json")
+		if err := writePseudonymTable(path, f.Result.Pseudonyms); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"rtcstats"
+)
+
+// runExpand handles "rtcstats expand": rewrite compressed output readably.
+func runExpand(args []string) {
+	fs := flag.NewFlagSet("rtcstats expand", flag.ExitOnError)
+	outputFile := fs.String("o", "", "Output file (default: stdout)")
+	output := fs.String("output", "", "Output file (default: stdout)")
+	pretty := fs.Bool("pretty", false, "Pretty-print JSON output")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: rtcstats expand [flags] <compressed-file|->\n\n")
+		fmt.Fprintf(os.Stderr, "Rewrites compressed output with full field names, state names and\n")
+		fmt.Fprintf(os.Stderr, "RFC 3339 times, for human review. Output written with --ts delta has no\n")
+		fmt.Fprintf(os.Stderr, "absolute times; its records get elapsedMs (ms since the first event).\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Fprintf(os.Stderr, "Error: input file required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	outPath := *outputFile
+	if outPath == "" {
+		outPath = *output
+	}
+	var opts []rtcstats.Option
+	if *pretty {
+		opts = append(opts, rtcstats.WithPrettyPrint())
+	}
+
+	if err := rtcstats.ExpandStats(fs.Arg(0), outPath, opts...); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
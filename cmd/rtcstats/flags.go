@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"rtcstats"
+)
+
+// processFlags holds the flags shared by every processing command.
+type processFlags struct {
+	outputFile *string
+	output     *string
+	tsMode     *string
+	pretty     *bool
+	format     *string
+	quiet      *bool
+	quietLong  *bool
+	sample     *bool
+	sampleN    *int
+	sampleCtx  *int
+	statsDelta *string
+	lenient    *bool
+	reorder    *time.Duration
+	tabular    *bool
+	budget     *int
+	chunk      *int
+	legend     *bool
+	correlate  *bool
+	collapse   *bool
+	collapseW  *time.Duration
+	iceAgg     *bool
+	pseudo     *bool
+	salt       *string
+	pseudoOut  *string
+	include    *string
+	exclude    *string
+	scope      *string
+	from       *string
+	to         *string
+}
+
+// registerProcessFlags defines the processing flags on fs.
+func registerProcessFlags(fs *flag.FlagSet) *processFlags {
+	return &processFlags{
+		outputFile: fs.String("o", "", "Output file (default: stdout)"),
+		output:     fs.String("output", "", "Output file (default: stdout)"),
+		tsMode:     fs.String("ts", "absolute", "Timestamp mode: absolute|delta|both"),
+		pretty:     fs.Bool("pretty", false, "Pretty-print JSON output (same as --format json)"),
+		format:     fs.String("format", "jsonl", "Output format: jsonl|json|compact"),
+		quiet:      fs.Bool("q", false, "Suppress stats output"),
+		quietLong:  fs.Bool("quiet", false, "Suppress stats output"),
+		sample:     fs.Bool("sample", false, "Enable adaptive sampling for getstats events"),
+		sampleN:    fs.Int("sample-n", 5, "Sampling interval: keep every Nth getstats sample"),
+		sampleCtx:  fs.Int("sample-ctx", 2, "Context window: samples before/after interesting moments"),
+		statsDelta: fs.String("stats-delta", "auto", "Client delta-compressed getstats payloads: auto|on|off"),
+		lenient:    fs.Bool("lenient", false, "Skip malformed records and print a summary of what was skipped"),
+		reorder:    fs.Duration("reorder", 0, "Reorder window: sort events by ts within this window (e.g. 2s)"),
+		budget:     fs.Int("budget", 0, "Token budget: pick the highest-fidelity sampling that fits (overrides --sample*)"),
+		tabular:    fs.Bool("tabular", false, "Emit getstats as column headers once per series, then value rows"),
+		legend:     fs.Bool("legend", false, "Start the output with a legend of the abbreviations, enum codes and scopes it uses"),
+		correlate:  fs.Bool("correlate", false, "Link requests to their results with rid/dur and flag requests that never completed"),
+		collapse:   fs.Bool("collapse", false, "Merge consecutive repeated identical events into one record with a repeat count and time span"),
+		collapseW:  fs.Duration("collapse-window", rtcstats.DefaultCollapseWindow, "Longest run of repeats merged into one record"),
+		iceAgg:     fs.Bool("collapse-ice", false, "Merge runs of ICE candidate events into counts by type and transport (implies --collapse)"),
+		pseudo:     fs.Bool("pseudonymize", false, "Replace user, session, device and group IDs with aliases (u1, s1, d1, g1)"),
+		salt:       fs.String("pseudonym-salt", "", "Derive aliases from a hash of the ID keyed by this salt, stable across files (implies --pseudonymize)"),
+		pseudoOut:  fs.String("pseudonym-table", "", "Write the alias table (alias to ID) to this JSON file (implies --pseudonymize; for batch, a directory that gets one <input name>.json per input)"),
+		chunk:      fs.Int("chunk-tokens", 0, "Split output into self-contained chunks of at most N tokens (numbered files with -o)"),
+		include:    fs.String("include", "", "Only output these events (comma-separated names, * matches any run of characters)"),
+		exclude:    fs.String("exclude", "", "Do not output these events (comma-separated names, * allowed)"),
+		scope:      fs.String("scope", "", "Only output events of these scopes (comma-separated, * allowed, e.g. 0-pub,sfu:*)"),
+		from:       fs.String("from", "", "Only output events from this time: ms or duration since the first event (60000, 1m), epoch ms or RFC 3339"),
+		to:         fs.String("to", "", "Only output events up to this time, in the same forms as --from"),
+	}
+}
+
+// outPath resolves the -o/--output aliases.
+func (f *processFlags) outPath() string {
+	if *f.outputFile != "" {
+		return *f.outputFile
+	}
+	return *f.output
+}
+
+func (f *processFlags) isQuiet() bool {
+	return *f.quiet || *f.quietLong
+}
+
+// options converts the flags to library options, exiting on invalid values.
+func (f *processFlags) options() []rtcstats.Option {
+	// Parse timestamp mode
+	var timestampMode rtcstats.TimestampMode
+	switch strings.ToLower(*f.tsMode) {
+	case "absolute", "abs":
+		timestampMode = rtcstats.TSAbsolute
+	case "delta", "dt":
+		timestampMode = rtcstats.TSDelta
+	case "both":
+		timestampMode = rtcstats.TSBoth
+	default:
+		fmt.Fprintf(os.Stderr, "Error: invalid timestamp mode: %s (use: absolute|delta|both)\n", *f.tsMode)
+		os.Exit(1)
+	}
+
+	// Parse stats delta mode
+	var deltaMode rtcstats.StatsDeltaMode
+	switch strings.ToLower(*f.statsDelta) {
+	case "auto":
+		deltaMode = rtcstats.StatsDeltaAuto
+	case "on":
+		deltaMode = rtcstats.StatsDeltaOn
+	case "off":
+		deltaMode = rtcstats.StatsDeltaOff
+	default:
+		fmt.Fprintf(os.Stderr, "Error: invalid stats delta mode: %s (use: auto|on|off)\n", *f.statsDelta)
+		os.Exit(1)
+	}
+
+	// Parse output format
+	var format rtcstats.OutputFormat
+	switch strings.ToLower(*f.format) {
+	case "jsonl":
+		format = rtcstats.FormatJSONL
+	case "json", "pretty":
+		format = rtcstats.FormatJSON
+	case "compact":
+		format = rtcstats.FormatCompact
+	default:
+		fmt.Fprintf(os.Stderr, "Error: invalid output format: %s (use: jsonl|json|compact)\n", *f.format)
+		os.Exit(1)
+	}
+	if *f.pretty {
+		format = rtcstats.FormatJSON
+	}
+
+	// Build options
+	opts := []rtcstats.Option{
+		rtcstats.WithTimestampMode(timestampMode),
+		rtcstats.WithStatsDeltaMode(deltaMode),
+		rtcstats.WithOutputFormat(format),
+	}
+	if !f.isQuiet() {
+		opts = append(opts, rtcstats.WithLogger(rtcstats.StderrLogger()))
+	}
+	if *f.sample {
+		opts = append(opts, rtcstats.WithSampling())
+		if *f.sampleN != 5 {
+			opts = append(opts, rtcstats.WithSamplingInterval(*f.sampleN))
+		}
+		if *f.sampleCtx != 2 {
+			opts = append(opts, rtcstats.WithSamplingContext(*f.sampleCtx, *f.sampleCtx))
+		}
+	}
+	if *f.lenient {
+		opts = append(opts, rtcstats.WithLenientParsing())
+	}
+	if *f.budget > 0 {
+		opts = append(opts, rtcstats.WithTokenBudget(*f.budget))
+	}
+	if *f.chunk > 0 {
+		opts = append(opts, rtcstats.WithChunking(*f.chunk))
+	}
+	if *f.legend {
+		opts = append(opts, rtcstats.WithLegend())
+	}
+	if *f.tabular {
+		opts = append(opts, rtcstats.WithTabularStats())
+	}
+	if *f.correlate {
+		opts = append(opts, rtcstats.WithCorrelation())
+	}
+	if *f.collapse || *f.iceAgg {
+		opts = append(opts, rtcstats.WithCollapseWindow(*f.collapseW))
+	}
+	if *f.iceAgg {
+		opts = append(opts, rtcstats.WithICEAggregation())
+	}
+	if *f.salt != "" {
+		opts = append(opts, rtcstats.WithPseudonymSalt(*f.salt))
+	} else if *f.pseudo || *f.pseudoOut != "" {
+		opts = append(opts, rtcstats.WithPseudonymization())
+	}
+	if *f.reorder > 0 {
+		opts = append(opts, rtcstats.WithReorderWindow(*f.reorder))
+	}
+	if sel, ok := f.selector(); ok {
+		opts = append(opts, rtcstats.WithEventSelector(sel))
+	}
+
+	return opts
+}
+
+// selector builds the event filter set by --include, --exclude, --scope,
+// --from and --to, exiting on invalid times. ok is false when none is set.
+func (f *processFlags) selector() (sel rtcstats.EventSelector, ok bool) {
+	sel = rtcstats.EventSelector{
+		Include: splitList(*f.include),
+		Exclude: splitList(*f.exclude),
+		Scopes:  splitList(*f.scope),
+		From:    parseEventTime("from", *f.from),
+		To:      parseEventTime("to", *f.to),
+	}
+	ok = len(sel.Include) > 0 || len(sel.Exclude) > 0 || len(sel.Scopes) > 0 || sel.From != 0 || sel.To != 0
+	return sel, ok
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// parseEventTime parses a --from/--to value into EventSelector form: ms
+// since the first event, or epoch ms. "" is 0 (open).
+func parseEventTime(name, s string) int64 {
+	if s == "" {
+		return 0
+	}
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return ms
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return d.Milliseconds()
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t.UnixMilli()
+	}
+	fmt.Fprintf(os.Stderr, "Error: invalid --%s time: %s (use ms, a duration such as 90s, epoch ms or RFC 3339)\n", name, s)
+	os.Exit(1)
+	return 0
+}
+
+// report prints post-processing summaries requested by flags and writes
+// the alias table.
+func (f *processFlags) report(res *rtcstats.Result) {
+	if *f.lenient && !f.isQuiet() {
+		printSkipped(res.SkippedRecords)
+	}
+	if *f.pseudoOut != "" {
+		if err := writePseudonymTable(*f.pseudoOut, res.Pseudonyms); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// writePseudonymTable writes the alias table to path as JSON, readable by
+// its owner only.
+func writePseudonymTable(path string, table map[string]string) error {
+	data, err := json.MarshalIndent(table, "", "  ")
+	if err != nil {
+		return fmt.Errorf("writing pseudonym table: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("writing pseudonym table: %w", err)
+	}
+	return nil
+}
+
+// printSkipped writes a summary of records dropped by lenient parsing to stderr.
+func printSkipped(skipped []rtcstats.SkippedRecord) {
+	if len(skipped) == 0 {
+		return
+	}
+	const maxListed = 10
+	fmt.Fprintf(os.Stderr, "Skipped %d malformed record(s):\n", len(skipped))
+	for i, s := range skipped {
+		if i == maxListed {
+			fmt.Fprintf(os.Stderr, "  ... and %d more\n", len(skipped)-maxListed)
+			break
+		}
+		where := ""
+		if s.Input != "" {
+			where = s.Input + " "
+		}
+		fmt.Fprintf(os.Stderr, "  %srecord %d at byte %d: %s\n", where, s.Index, s.Offset, s.Reason)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"rtcstats"
+)
+
+// runMerge handles "rtcstats merge": interleave several participant dumps.
+func runMerge(args []string) {
+	fs := flag.NewFlagSet("rtcstats merge", flag.ExitOnError)
+	flags := registerProcessFlags(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: rtcstats merge [flags] <input-file> <input-file>...\n\n")
+		fmt.Fprintf(os.Stderr, "Merges one dump per participant into a single timeline sorted by ts.\n")
+		fmt.Fprintf(os.Stderr, "Scopes are prefixed with participant aliases (p1/0-pub, p2/0-sub, ...).\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Fprintf(os.Stderr, "Error: at least one input file required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	res, err := rtcstats.MergeStats(fs.Args(), flags.outPath(), flags.options()...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !flags.isQuiet() {
+		for _, p := range res.Participants {
+			fmt.Fprintf(os.Stderr, "  %s: %s (uid=%s)\n", p.Alias, p.Source, p.UserID)
+		}
+	}
+	flags.report(res)
+}
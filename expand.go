@@ -0,0 +1,61 @@
+package rtcstats
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"rtcstats/internal/expand"
+	"rtcstats/internal/ioutil"
+)
+
+// Expand reads compressed output (JSONL, indented JSON or compact arrays,
+// optionally gzip/zstd compressed) from r and writes it to w in readable
+// form: full WebRTC field and stats type names, state names instead of enum
+// codes, and RFC 3339 times. Records that only have a delta timestamp
+// (TSDelta output) carry no absolute time, so they get elapsedMs, the ms
+// since the first event, instead. Tabular getstats rows are mapped back to
+// named fields. Counter values remain deltas, as in the input.
+// Only WithPrettyPrint (or WithOutputFormat(FormatJSON)) affects the output.
+func Expand(r io.Reader, w io.Writer, opts ...Option) error {
+	cfg := applyOpts(opts)
+
+	dr, _, err := ioutil.NewDecompressReader(r)
+	if err != nil {
+		return fmt.Errorf("reading input: %w", err)
+	}
+	defer dr.Close()
+
+	if err := expand.New().Run(dr, w, cfg.format == FormatJSON); err != nil {
+		return fmt.Errorf("expanding: %w", err)
+	}
+	return nil
+}
+
+// ExpandStats is the file-to-file form of Expand. "-" reads from stdin;
+// "" or "-" as outputPath writes to stdout.
+func ExpandStats(inputPath, outputPath string, opts ...Option) error {
+	var src io.Reader = os.Stdin
+	if inputPath != "-" {
+		inFile, err := os.Open(inputPath)
+		if err != nil {
+			return fmt.Errorf("reading input: %w", err)
+		}
+		defer inFile.Close()
+		src = inFile
+	}
+
+	out, err := createOutput(outputPath)
+	if err != nil {
+		return err
+	}
+
+	if err := Expand(src, out, opts...); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package rtcstats
+
+import (
+	"strings"
+
+	"rtcstats/internal/processor"
+)
+
+// EventInfo describes an input event to an event filter: its name, its
+// scope as written in the output, its ts and the ms elapsed since the
+// first input event.
+type EventInfo = processor.EventInfo
+
+// WithEventFilter only outputs the input events for which keep returns
+// true. Filters run before the handlers. When given several times, an
+// event must pass every filter.
+//
+// Rejected events still update handler state. Counter deltas are not
+// cumulative across rejected getstats samples: each rejected sample is the
+// baseline of the next one, so the first delta after a gap covers only
+// that one sample interval, and totals summed from the output (see package
+// reader) miss what the gap added. With WithCorrelation, rejected requests
+// and results are still paired, so a written result keeps its rid and dur
+// even if its request was rejected.
+func WithEventFilter(keep func(e EventInfo) bool) Option {
+	return func(o *options) { o.filters = append(o.filters, keep) }
+}
+
+// WithEventSelector is WithEventFilter(sel.Keep), except that ProcessDir
+// can compare sel with the selector of an earlier run when deciding
+// whether that run's output is up to date.
+func WithEventSelector(sel EventSelector) Option {
+	return func(o *options) {
+		o.filters = append(o.filters, sel.Keep)
+		o.selectors = append(o.selectors, sel)
+	}
+}
+
+// EventSelector selects events by name, scope and time, as the CLI's
+// --include, --exclude, --scope, --from and --to flags do. Pass it to
+// WithEventSelector, or its Keep method to WithEventFilter.
+//
+// Name and scope patterns may use * to match any run of characters, e.g.
+// "sfu.*" or "p1/*".
+type EventSelector struct {
+	Include []string // event names to keep; empty keeps all
+	Exclude []string // event names to drop, even if included
+	Scopes  []string // scopes to keep; empty keeps all
+
+	// From and To bound the time range, inclusively; 0 leaves that end
+	// open. Values from AbsoluteTSThreshold up are epoch-ms timestamps,
+	// smaller ones ms since the first input event.
+	From int64
+	To   int64
+}
+
+// AbsoluteTSThreshold separates relative EventSelector times from
+// absolute ones: 1e12 ms after the epoch is September 2001.
+const AbsoluteTSThreshold = 1_000_000_000_000
+
+// Keep reports whether e matches the selector.
+func (s EventSelector) Keep(e EventInfo) bool {
+	if len(s.Include) > 0 && !matchAny(s.Include, e.Name) {
+		return false
+	}
+	if matchAny(s.Exclude, e.Name) {
+		return false
+	}
+	if len(s.Scopes) > 0 && !matchAny(s.Scopes, e.Scope) {
+		return false
+	}
+	if s.From != 0 && selectorTime(s.From, e) < s.From {
+		return false
+	}
+	if s.To != 0 && selectorTime(s.To, e) > s.To {
+		return false
+	}
+	return true
+}
+
+// selectorTime returns the time of e on the same scale as bound.
+func selectorTime(bound int64, e EventInfo) int64 {
+	if bound >= AbsoluteTSThreshold {
+		return e.TS
+	}
+	return e.Elapsed
+}
+
+// matchAny reports whether s matches one of patterns.
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if matchPattern(p, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern matches s against a pattern in which * stands for any run
+// of characters.
+func matchPattern(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return len(s) >= len(last) && strings.HasSuffix(s, last)
+}
+
+// eventFilter combines the filters set with WithEventFilter, or returns
+// nil if there are none.
+func eventFilter(cfg options) func(e EventInfo) bool {
+	if len(cfg.filters) == 0 {
+		return nil
+	}
+	filters := cfg.filters
+	return func(e EventInfo) bool {
+		for _, keep := range filters {
+			if !keep(e) {
+				return false
+			}
+		}
+		return true
+	}
+}
@@ -0,0 +1,80 @@
+package rtcstats
+
+import (
+	"fmt"
+
+	"rtcstats/internal/event"
+	"rtcstats/internal/handlers"
+)
+
+// RawEvent is one input event as passed to a Handler: its name, scope
+// (nil when the record has none), raw JSON payload and ts in epoch ms.
+type RawEvent = event.RawEvent
+
+// Handler compresses the payload of one kind of event. Transform returns
+// the payload to write, typically a map with short keys; nil writes the
+// event without a payload. Register one with WithHandler or
+// WithPrefixHandler to compress custom events or replace a built-in
+// handler.
+//
+// The same Handler serves every pipeline built with the option, including
+// the concurrent ones of ProcessDir and the repeated passes of
+// WithTokenBudget, so a Handler that keeps state must be safe for that.
+type Handler = handlers.Handler
+
+// HandlerFunc adapts a function to a Handler.
+type HandlerFunc = handlers.HandlerFunc
+
+// WithHandler makes h handle events named name, replacing any built-in
+// handler for them. The getstats handler cannot be replaced, as sampling,
+// chunking and filtering depend on its delta state.
+func WithHandler(name string, h Handler) Option {
+	return func(o *options) {
+		o.handlers = append(o.handlers, func(r *handlers.Registry) { r.Register(name, h) })
+		if name == "getstats" {
+			o.replacesGetStats = true
+		}
+	}
+}
+
+// WithPrefixHandler makes h handle events whose name starts with prefix
+// and that have no exact-name handler. The longest matching prefix wins.
+func WithPrefixHandler(prefix string, h Handler) Option {
+	return func(o *options) {
+		o.handlers = append(o.handlers, func(r *handlers.Registry) { r.RegisterPrefix(prefix, h) })
+	}
+}
+
+// WithSuffixHandler makes h handle events whose name ends with suffix and
+// that have neither an exact-name nor a prefix handler. The longest
+// matching suffix wins.
+func WithSuffixHandler(suffix string, h Handler) Option {
+	return func(o *options) {
+		o.handlers = append(o.handlers, func(r *handlers.Registry) { r.RegisterSuffix(suffix, h) })
+	}
+}
+
+// registerHandlers returns a function adding the handlers set with
+// WithHandler and friends to a pipeline's registry, or nil if there are
+// none.
+func registerHandlers(cfg options) func(r *handlers.Registry) {
+	if len(cfg.handlers) == 0 {
+		return nil
+	}
+	return func(r *handlers.Registry) {
+		for _, register := range cfg.handlers {
+			register(r)
+		}
+	}
+}
+
+// checkOptions reports options that cannot be used together.
+func checkOptions(cfg options) error {
+	if cfg.tokenBudget > 0 && cfg.chunkTokens > 0 {
+		return fmt.Errorf("token budget and chunking cannot be combined")
+	}
+	if cfg.replacesGetStats {
+		return fmt.Errorf("the getstats handler cannot be replaced")
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package event
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Decoder reads CompressedEvents back from output written by any of the
+// built-in encoders: JSONL, indented JSON or compact positional arrays.
+// Payloads are decoded into generic JSON values.
+type Decoder struct {
+	dec   *json.Decoder
+	count int
+}
+
+// NewDecoder creates a Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{dec: json.NewDecoder(bufio.NewReader(r))}
+}
+
+// Next returns the next record, or io.EOF when the input is exhausted.
+func (d *Decoder) Next() (CompressedEvent, error) {
+	var raw json.RawMessage
+	if err := d.dec.Decode(&raw); err != nil {
+		if err == io.EOF {
+			return CompressedEvent{}, io.EOF
+		}
+		return CompressedEvent{}, fmt.Errorf("record %d: %w", d.count, err)
+	}
+	ce, err := DecodeCompressed(raw)
+	if err != nil {
+		return CompressedEvent{}, fmt.Errorf("record %d: %w", d.count, err)
+	}
+	d.count++
+	return ce, nil
+}
+
+// DecodeCompressed parses one output record: a JSON object or a compact
+// [n, s, p, ts|dt] array.
+func DecodeCompressed(raw []byte) (CompressedEvent, error) {
+	var ce CompressedEvent
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || raw[0] != '[' {
+		err := json.Unmarshal(raw, &ce)
+		return ce, err
+	}
+
+	var arr []json.RawMessage
+	if err := json.Unmarshal(raw, &arr); err != nil {
+		return ce, err
+	}
+	if len(arr) < 3 {
+		return ce, fmt.Errorf("compact record has %d elements, need at least 3", len(arr))
+	}
+	if err := json.Unmarshal(arr[0], &ce.Name); err != nil {
+		return ce, fmt.Errorf("name: %w", err)
+	}
+	if err := json.Unmarshal(arr[1], &ce.Scope); err != nil {
+		return ce, fmt.Errorf("scope: %w", err)
+	}
+	if err := json.Unmarshal(arr[2], &ce.Payload); err != nil {
+		return ce, fmt.Errorf("payload: %w", err)
+	}
+
+	var times []int64
+	for _, t := range arr[3:] {
+		var v int64
+		if err := json.Unmarshal(t, &v); err != nil {
+			return ce, fmt.Errorf("timestamp: %w", err)
+		}
+		times = append(times, v)
+	}
+	switch {
+	case len(times) >= 2:
+		ce.TS, ce.DT = times[0], &times[1]
+	case len(times) == 1 && isEpochMs(times[0]):
+		ce.TS = times[0]
+	case len(times) == 1:
+		ce.DT = &times[0]
+	}
+	return ce, nil
+}
+
+// isEpochMs tells absolute timestamps from deltas in compact records,
+// which only differ by magnitude (anything after 2001 is absolute).
+func isEpochMs(v int64) bool {
+	return v >= 1e12
+}
@@ -0,0 +1,92 @@
+package event
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Encoder serializes CompressedEvents to an output stream. The built-in
+// encoders write map keys in sorted order, so equal input always gives
+// byte-identical output.
+type Encoder interface {
+	Encode(e CompressedEvent) error
+}
+
+// OutputFormat selects a built-in Encoder.
+type OutputFormat int
+
+const (
+	FormatJSONL      OutputFormat = iota // one JSON object per line (default)
+	FormatPrettyJSON                     // indented JSON objects
+	FormatCompact                        // positional arrays: [n,s,p,ts|dt]
+)
+
+// NewEncoder returns the built-in Encoder for format.
+func NewEncoder(w io.Writer, format OutputFormat) Encoder {
+	switch format {
+	case FormatPrettyJSON:
+		return &JSONEncoder{w: w, pretty: true}
+	case FormatCompact:
+		return &CompactEncoder{w: w}
+	default:
+		return &JSONEncoder{w: w}
+	}
+}
+
+// JSONEncoder writes CompressedEvents as JSON objects, one per line unless pretty.
+type JSONEncoder struct {
+	w      io.Writer
+	pretty bool
+}
+
+// Encode outputs a CompressedEvent as JSON
+func (enc *JSONEncoder) Encode(e CompressedEvent) error {
+	var data []byte
+	var err error
+
+	if enc.pretty {
+		data, err = json.MarshalIndent(e, "", "  ")
+	} else {
+		data, err = json.Marshal(e)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = enc.w.Write(append(data, '\n'))
+	return err
+}
+
+// CompactEncoder writes each CompressedEvent as a positional JSON array,
+// dropping the repeated "n"/"s"/"p" keys:
+//
+//	[n, s, p, ts]     absolute timestamps
+//	[n, s, p, dt]     delta timestamps
+//	[n, s, p, ts, dt] both
+//
+// Records without a timestamp (headers) are written as [n, s, p].
+type CompactEncoder struct {
+	w io.Writer
+}
+
+// Encode outputs a CompressedEvent as a positional array
+func (enc *CompactEncoder) Encode(e CompressedEvent) error {
+	rec := []interface{}{e.Name, e.Scope, e.Payload}
+	switch {
+	case e.DT != nil && e.TS != 0:
+		rec = append(rec, e.TS, *e.DT)
+	case e.DT != nil:
+		rec = append(rec, *e.DT)
+	case e.TS != 0:
+		rec = append(rec, e.TS)
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	_, err = enc.w.Write(append(data, '\n'))
+	return err
+}
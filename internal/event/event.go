@@ -10,6 +10,14 @@ type RawEvent struct {
 	TS      int64
 }
 
+// Source yields RawEvents one at a time.
+type Source interface {
+	// Next returns the next event, or io.EOF when the source is exhausted.
+	Next() (RawEvent, error)
+	// Count returns the number of events returned so far.
+	Count() int
+}
+
 // CompressedEvent represents the output format
 type CompressedEvent struct {
 	Name    string      `json:"n"`
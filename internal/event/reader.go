@@ -1,133 +1,268 @@
 package event
 
 import (
-	"bytes"
+	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"os"
 )
 
-// Reader reads RawEvents from JSON or JSONL files
+// Format identifies the layout of an input stream.
+type Format int
+
+const (
+	FormatUnknown         Format = iota
+	FormatRTCStats               // stream of [name, scope, payload, ts] arrays
+	FormatWebRTCInternals        // chrome://webrtc-internals JSON dump object
+)
+
+// DetectFormat skips leading whitespace in br and inspects the first byte
+// without consuming it. Empty input is reported as FormatRTCStats.
+func DetectFormat(br *bufio.Reader) (Format, error) {
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return FormatRTCStats, nil
+		}
+		if err != nil {
+			return FormatUnknown, fmt.Errorf("reading input: %w", err)
+		}
+		if isSpace(b) {
+			continue
+		}
+		if err := br.UnreadByte(); err != nil {
+			return FormatUnknown, err
+		}
+		switch b {
+		case '[':
+			return FormatRTCStats, nil
+		case '{':
+			return FormatWebRTCInternals, nil
+		default:
+			return FormatUnknown, nil
+		}
+	}
+}
+
+// SkippedRecord describes an input record dropped in lenient mode.
+type SkippedRecord struct {
+	Index  int    `json:"index"`  // 1-based record number, as used in error messages
+	Offset int64  `json:"offset"` // byte offset of the record in the (decompressed) input
+	Reason string `json:"reason"`
+	Input  string `json:"input,omitempty"` // participant alias when merging several inputs
+}
+
+// Reader decodes RawEvents one record at a time from a JSON or JSONL stream.
+// Only the record currently being decoded is held in memory.
 type Reader struct {
-	events []RawEvent
+	src     io.Reader // stream remaining after the decoder's buffer
+	dec     *json.Decoder
+	base    int64 // stream offset at which dec started reading
+	records int   // records attempted, including skipped ones
+	count   int   // events successfully decoded
+	lenient bool
+	skipped []SkippedRecord
 }
 
-// NewReaderFromFile creates a Reader from a file path
-func NewReaderFromFile(path string) (*Reader, error) {
-	data, err := os.ReadFile(path)
+// NewReader creates a Reader over r. It peeks at the first non-whitespace
+// byte to verify the input is a stream of JSON arrays. In lenient mode,
+// malformed records are skipped and recorded instead of failing the read,
+// including a first record that is not an array.
+func NewReader(r io.Reader, lenient bool) (*Reader, error) {
+	br := bufio.NewReader(r)
+
+	lead, err := skipSpace(br)
 	if err != nil {
-		return nil, fmt.Errorf("reading file: %w", err)
+		return nil, err
 	}
-	return NewReader(data)
-}
 
-// NewReader creates a Reader from raw bytes, auto-detecting JSON vs JSONL
-func NewReader(data []byte) (*Reader, error) {
-	data = bytes.TrimSpace(data)
-	if len(data) == 0 {
-		return &Reader{events: []RawEvent{}}, nil
-	}
+	// Streaming JSON decoder - handles both single-line and multi-line JSONL
+	rd := &Reader{src: br, dec: json.NewDecoder(br), base: lead, lenient: lenient}
 
-	// Use streaming JSON decoder - handles both single-line and multi-line JSONL
-	if data[0] == '[' {
-		return parseJSONStream(data)
+	b, err := br.Peek(1)
+	if err == io.EOF {
+		return rd, nil
 	}
-
-	return nil, fmt.Errorf("expected JSON array, got: %c", data[0])
+	if err != nil {
+		return nil, fmt.Errorf("reading input: %w", err)
+	}
+	if b[0] != '[' {
+		reason := fmt.Sprintf("expected JSON array, got: %c", b[0])
+		if !lenient {
+			return nil, errors.New(reason)
+		}
+		rd.records++
+		rd.skip(rd.records, lead, reason)
+		if err := rd.resync(lead); err != nil {
+			return nil, err
+		}
+	}
+	return rd, nil
 }
 
-func parseJSONStream(data []byte) (*Reader, error) {
-	var events []RawEvent
+// skipSpace discards leading whitespace in br and returns how many bytes
+// it discarded.
+func skipSpace(br *bufio.Reader) (int64, error) {
+	var n int64
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, fmt.Errorf("reading input: %w", err)
+		}
+		if !isSpace(b[0]) {
+			return n, nil
+		}
+		br.ReadByte()
+		n++
+	}
+}
 
-	// Use a streaming approach to handle multi-line JSON arrays
-	decoder := json.NewDecoder(bytes.NewReader(data))
+// Next decodes and returns the next event. It returns io.EOF when the
+// stream is exhausted.
+func (r *Reader) Next() (RawEvent, error) {
+	for r.dec != nil && r.dec.More() {
+		r.records++
+		eventNum := r.records
+		// More() has skipped leading whitespace, so this is the record start
+		offset := r.base + r.dec.InputOffset()
 
-	eventNum := 0
-	for decoder.More() {
-		eventNum++
 		var raw []json.RawMessage
-		if err := decoder.Decode(&raw); err != nil {
-			return nil, fmt.Errorf("event %d: %w", eventNum, err)
+		if err := r.dec.Decode(&raw); err != nil {
+			if !r.lenient {
+				return RawEvent{}, fmt.Errorf("event %d: %w", eventNum, err)
+			}
+			r.skip(eventNum, offset, err.Error())
+			if _, ok := err.(*json.UnmarshalTypeError); !ok {
+				// Syntax errors leave the decoder stuck; restart at the next line
+				if err := r.resync(offset); err != nil {
+					return RawEvent{}, err
+				}
+			}
+			continue
 		}
 
-		if len(raw) < 4 {
-			return nil, fmt.Errorf("event %d: array has %d elements, need 4", eventNum, len(raw))
+		e, err := parseRecord(raw)
+		if err != nil {
+			if !r.lenient {
+				return RawEvent{}, fmt.Errorf("event %d: %w", eventNum, err)
+			}
+			r.skip(eventNum, offset, err.Error())
+			continue
 		}
 
-		var event RawEvent
+		r.count++
+		return e, nil
+	}
+	return RawEvent{}, io.EOF
+}
 
-		// Parse event name (string)
-		if err := json.Unmarshal(raw[0], &event.Name); err != nil {
-			return nil, fmt.Errorf("event %d: parsing event name: %w", eventNum, err)
-		}
+// Count returns the number of events successfully read so far.
+func (r *Reader) Count() int {
+	return r.count
+}
 
-		// Parse scope (nullable string)
-		if string(raw[1]) != "null" {
-			var scope string
-			if err := json.Unmarshal(raw[1], &scope); err != nil {
-				return nil, fmt.Errorf("event %d: parsing scope: %w", eventNum, err)
-			}
-			event.Scope = &scope
-		}
+// Skipped returns the records dropped so far in lenient mode.
+func (r *Reader) Skipped() []SkippedRecord {
+	return r.skipped
+}
+
+func (r *Reader) skip(eventNum int, offset int64, reason string) {
+	r.skipped = append(r.skipped, SkippedRecord{Index: eventNum, Offset: offset, Reason: reason})
+}
 
-		// Keep payload as raw JSON
-		event.Payload = raw[2]
+// resync discards the malformed record starting at offset up to the next
+// line that starts a top-level value ('[' or '{' in column 0), then
+// restarts decoding there. Lines inside the broken record, such as the
+// rest of a pretty-printed array, are discarded with it.
+func (r *Reader) resync(offset int64) error {
+	br := bufio.NewReader(io.MultiReader(r.dec.Buffered(), r.src))
 
-		// Parse timestamp (int64)
-		if err := json.Unmarshal(raw[3], &event.TS); err != nil {
-			return nil, fmt.Errorf("event %d: parsing timestamp: %w", eventNum, err)
+	// The decoder's buffer may still hold whitespace preceding the record
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			r.dec = nil
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading input: %w", err)
+		}
+		if !isSpace(b) {
+			break
 		}
-
-		events = append(events, event)
 	}
 
-	return &Reader{events: events}, nil
-}
+	skipped := int64(1)
+	for {
+		line, err := br.ReadSlice('\n')
+		skipped += int64(len(line))
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			r.dec = nil
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading input: %w", err)
+		}
 
-// Events returns a channel that yields events
-func (r *Reader) Events() <-chan RawEvent {
-	ch := make(chan RawEvent)
-	go func() {
-		defer close(ch)
-		for _, e := range r.events {
-			ch <- e
+		next, err := br.Peek(1)
+		if err == io.EOF {
+			r.dec = nil
+			return nil
 		}
-	}()
-	return ch
-}
+		if err != nil {
+			return fmt.Errorf("reading input: %w", err)
+		}
+		if next[0] == '[' || next[0] == '{' {
+			break
+		}
+	}
 
-// AllEvents returns all events as a slice
-func (r *Reader) AllEvents() []RawEvent {
-	return r.events
+	r.src = br
+	r.dec = json.NewDecoder(br)
+	r.base = offset + skipped
+	return nil
 }
 
-// Writer writes CompressedEvents as JSONL
-type Writer struct {
-	w      io.Writer
-	pretty bool
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
 }
 
-// NewWriter creates a new Writer
-func NewWriter(w io.Writer, pretty bool) *Writer {
-	return &Writer{w: w, pretty: pretty}
-}
+// parseRecord converts a decoded [name, scope, payload, ts] array into a RawEvent.
+func parseRecord(raw []json.RawMessage) (RawEvent, error) {
+	var event RawEvent
 
-// Write outputs a CompressedEvent as JSON
-func (w *Writer) Write(e CompressedEvent) error {
-	var data []byte
-	var err error
+	if len(raw) < 4 {
+		return event, fmt.Errorf("array has %d elements, need 4", len(raw))
+	}
 
-	if w.pretty {
-		data, err = json.MarshalIndent(e, "", "  ")
-	} else {
-		data, err = json.Marshal(e)
+	// Parse event name (string)
+	if err := json.Unmarshal(raw[0], &event.Name); err != nil {
+		return event, fmt.Errorf("parsing event name: %w", err)
 	}
 
-	if err != nil {
-		return err
+	// Parse scope (nullable string)
+	if string(raw[1]) != "null" {
+		var scope string
+		if err := json.Unmarshal(raw[1], &scope); err != nil {
+			return event, fmt.Errorf("parsing scope: %w", err)
+		}
+		event.Scope = &scope
+	}
+
+	// Keep payload as raw JSON
+	event.Payload = raw[2]
+
+	// Parse timestamp (int64)
+	if err := json.Unmarshal(raw[3], &event.TS); err != nil {
+		return event, fmt.Errorf("parsing timestamp: %w", err)
 	}
 
-	_, err = w.w.Write(append(data, '\n'))
-	return err
+	return event, nil
 }
@@ -0,0 +1,121 @@
+package event
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReaderLenient(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		events  []string
+		skipped []SkippedRecord // Reason is matched as a prefix
+		strict  string          // error in strict mode, "" if the input is valid
+	}{
+		{
+			name:   "valid",
+			input:  "[\"a\", \"s\", {}, 1]\n[\"b\", null, 1, 2]\n",
+			events: []string{"a", "b"},
+		},
+		{
+			name:    "syntax error resyncs at next line",
+			input:   "[\"a\", \"s\", {}, 1]\n[\"b\", \"s\", {,2]\n[\"c\", \"s\", {}, 3]\n",
+			events:  []string{"a", "c"},
+			skipped: []SkippedRecord{{Index: 2, Offset: 18, Reason: "invalid character"}},
+			strict:  "event 2: invalid character",
+		},
+		{
+			name:    "short array",
+			input:   "[\"a\", \"s\", {}, 1]\n[\"b\"]\n[\"c\", \"s\", {}, 3]\n",
+			events:  []string{"a", "c"},
+			skipped: []SkippedRecord{{Index: 2, Offset: 18, Reason: "array has 1 elements"}},
+			strict:  "event 2: array has 1 elements",
+		},
+		{
+			name:    "bad timestamp",
+			input:   "[\"a\", \"s\", {}, \"x\"]\n[\"b\", \"s\", {}, 2]\n",
+			events:  []string{"b"},
+			skipped: []SkippedRecord{{Index: 1, Offset: 0, Reason: "parsing timestamp"}},
+			strict:  "event 1: parsing timestamp",
+		},
+		{
+			name:    "broken pretty-printed record is dropped whole",
+			input:   "[\"a\",\n  \"s\",\n  {bad},\n  1]\n[\"b\", \"s\", {}, 2]\n",
+			events:  []string{"b"},
+			skipped: []SkippedRecord{{Index: 1, Offset: 0, Reason: "invalid character"}},
+			strict:  "event 1: invalid character",
+		},
+		{
+			name:    "bad leading record",
+			input:   "  garbage\n[\"a\", \"s\", {}, 1]\n",
+			events:  []string{"a"},
+			skipped: []SkippedRecord{{Index: 1, Offset: 2, Reason: "expected JSON array, got: g"}},
+			strict:  "expected JSON array, got: g",
+		},
+		{
+			name:    "offsets continue after a resync",
+			input:   "x\n[\"a\", \"s\", {}, 1]\n[\"b\", \"s\", {,2]\n[\"c\", \"s\", {}, 3]\n",
+			events:  []string{"a", "c"},
+			skipped: []SkippedRecord{{Index: 1, Offset: 0, Reason: "expected JSON array"}, {Index: 3, Offset: 20, Reason: "invalid character"}},
+			strict:  "expected JSON array",
+		},
+		{
+			name:    "nothing after a broken record",
+			input:   "[\"a\", \"s\", {}, 1]\n[\"b\", \"s\", {,2]\n",
+			events:  []string{"a"},
+			skipped: []SkippedRecord{{Index: 2, Offset: 18, Reason: "invalid character"}},
+			strict:  "event 2: invalid character",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, skipped, err := readAll(tt.input, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(events, tt.events) {
+				t.Errorf("events = %q, want %q", events, tt.events)
+			}
+			if len(skipped) != len(tt.skipped) {
+				t.Fatalf("skipped = %+v, want %+v", skipped, tt.skipped)
+			}
+			for i, s := range skipped {
+				want := tt.skipped[i]
+				if s.Index != want.Index || s.Offset != want.Offset || !strings.HasPrefix(s.Reason, want.Reason) {
+					t.Errorf("skipped[%d] = %+v, want %+v", i, s, want)
+				}
+			}
+
+			_, _, err = readAll(tt.input, false)
+			switch {
+			case tt.strict == "" && err != nil:
+				t.Errorf("strict: %v", err)
+			case tt.strict != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.strict)):
+				t.Errorf("strict: error %v, want %q", err, tt.strict)
+			}
+		})
+	}
+}
+
+// readAll returns the names of the events read from input and the
+// records skipped on the way.
+func readAll(input string, lenient bool) ([]string, []SkippedRecord, error) {
+	r, err := NewReader(strings.NewReader(input), lenient)
+	if err != nil {
+		return nil, nil, err
+	}
+	var names []string
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return names, r.Skipped(), nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		names = append(names, e.Name)
+	}
+}
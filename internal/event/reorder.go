@@ -0,0 +1,108 @@
+package event
+
+import (
+	"container/heap"
+	"io"
+)
+
+// pending is a buffered event with its arrival sequence for stable ordering.
+type pending struct {
+	ev  RawEvent
+	seq int
+}
+
+type pendingHeap []pending
+
+func (h pendingHeap) Len() int { return len(h) }
+func (h pendingHeap) Less(i, j int) bool {
+	if h[i].ev.TS != h[j].ev.TS {
+		return h[i].ev.TS < h[j].ev.TS
+	}
+	return h[i].seq < h[j].seq
+}
+func (h pendingHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *pendingHeap) Push(x interface{}) { *h = append(*h, x.(pending)) }
+func (h *pendingHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// ReorderSource wraps a Source and sorts its events by ts within a bounded
+// time window. An event is held until an event at least window ms newer has
+// been read, so records flushed late by up to window ms are put back in order.
+type ReorderSource struct {
+	src       Source
+	window    int64
+	buf       pendingHeap
+	seq       int
+	maxTS     int64
+	lastOut   int64
+	started   bool
+	eof       bool
+	count     int
+	reordered int
+	late      int
+}
+
+// NewReorderSource creates a ReorderSource with a window in milliseconds.
+func NewReorderSource(src Source, window int64) *ReorderSource {
+	return &ReorderSource{src: src, window: window}
+}
+
+// Next returns the next event in ts order.
+func (r *ReorderSource) Next() (RawEvent, error) {
+	for !r.eof && (r.buf.Len() == 0 || r.buf[0].ev.TS > r.maxTS-r.window) {
+		ev, err := r.src.Next()
+		if err == io.EOF {
+			r.eof = true
+			break
+		}
+		if err != nil {
+			return RawEvent{}, err
+		}
+
+		if r.seq > 0 && ev.TS < r.maxTS {
+			r.reordered++
+		}
+		if r.seq == 0 || ev.TS > r.maxTS {
+			r.maxTS = ev.TS
+		}
+		heap.Push(&r.buf, pending{ev: ev, seq: r.seq})
+		r.seq++
+	}
+
+	if r.buf.Len() == 0 {
+		return RawEvent{}, io.EOF
+	}
+
+	p := heap.Pop(&r.buf).(pending)
+	if r.started && p.ev.TS < r.lastOut {
+		// Arrived after the window closed; it cannot be put back in order
+		r.late++
+	}
+	if !r.started || p.ev.TS > r.lastOut {
+		r.lastOut = p.ev.TS
+	}
+	r.started = true
+	r.count++
+	return p.ev, nil
+}
+
+// Count returns the number of events returned so far.
+func (r *ReorderSource) Count() int {
+	return r.count
+}
+
+// Reordered returns how many events arrived with a ts older than an event
+// read before them.
+func (r *ReorderSource) Reordered() int {
+	return r.reordered
+}
+
+// Late returns how many of those arrived too late for the window and were
+// still emitted out of order.
+func (r *ReorderSource) Late() int {
+	return r.late
+}
@@ -0,0 +1,70 @@
+package event
+
+import (
+	"io"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// sliceSource returns events in a fixed order.
+type sliceSource struct {
+	events []RawEvent
+	count  int
+}
+
+func (s *sliceSource) Next() (RawEvent, error) {
+	if s.count == len(s.events) {
+		return RawEvent{}, io.EOF
+	}
+	s.count++
+	return s.events[s.count-1], nil
+}
+
+func (s *sliceSource) Count() int { return s.count }
+
+func TestReorderSource(t *testing.T) {
+	tests := []struct {
+		name      string
+		window    int64
+		ts        []int64
+		want      []int // indexes into ts, in output order
+		reordered int
+		late      int
+	}{
+		{"in order", 100, []int64{10, 20, 30}, []int{0, 1, 2}, 0, 0},
+		{"swap within window", 50, []int64{10, 30, 20, 40}, []int{0, 2, 1, 3}, 1, 0},
+		{"equal ts keep arrival order", 10, []int64{10, 10, 5}, []int{2, 0, 1}, 1, 0},
+		{"too late for the window", 100, []int64{0, 200, 300, 50}, []int{0, 1, 3, 2}, 1, 1},
+		{"zero window", 0, []int64{10, 5}, []int{0, 1}, 1, 1},
+		{"empty", 100, nil, nil, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var src sliceSource
+			for i, ts := range tt.ts {
+				src.events = append(src.events, RawEvent{Name: strconv.Itoa(i), TS: ts})
+			}
+			r := NewReorderSource(&src, tt.window)
+			var got []int
+			for {
+				e, err := r.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				i, _ := strconv.Atoi(e.Name)
+				got = append(got, i)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+			if r.Count() != len(tt.ts) || r.Reordered() != tt.reordered || r.Late() != tt.late {
+				t.Errorf("count, reordered, late = %d, %d, %d, want %d, %d, %d",
+					r.Count(), r.Reordered(), r.Late(), len(tt.ts), tt.reordered, tt.late)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+// Package expand rewrites compressed rtcstats output into a readable form:
+// full WebRTC field names, state names instead of enum codes, and RFC 3339
+// times, or ms since the first event when the input only has delta
+// timestamps. It is driven by the same tables the handlers compress with.
+package expand
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"rtcstats/internal/event"
+	"rtcstats/internal/handlers"
+	"rtcstats/internal/processor"
+	"rtcstats/internal/transform"
+)
+
+// Record is one expanded output record.
+type Record struct {
+	Event     string      `json:"event"`
+	Scope     string      `json:"scope,omitempty"`
+	Time      string      `json:"time,omitempty"`      // RFC 3339, UTC
+	Timestamp int64       `json:"timestamp,omitempty"` // epoch ms
+	ElapsedMs *int64      `json:"elapsedMs,omitempty"` // ms since the first event, only when the record has no absolute ts
+	Payload   interface{} `json:"payload,omitempty"`
+}
+
+// columnsEvent, participantsEvent and legendEvent name records with
+// special meaning in compressed output.
+const (
+	columnsEvent      = processor.ColumnsEvent
+	participantsEvent = "merge.participants"
+	legendEvent       = "legend"
+)
+
+// Expander converts compressed records. It keeps the column headers of
+// tabular getstats output, so records must be fed in order.
+type Expander struct {
+	tables *handlers.Detabulator
+}
+
+// New creates an Expander.
+func New() *Expander {
+	return &Expander{tables: handlers.NewDetabulator()}
+}
+
+// Run reads compressed records (JSONL, indented JSON or compact arrays)
+// from r and writes one expanded record per line to w, indented if pretty.
+func (x *Expander) Run(r io.Reader, w io.Writer, pretty bool) error {
+	dec := event.NewDecoder(r)
+	for {
+		ce, err := dec.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		rec, ok := x.expand(ce)
+		if !ok {
+			continue
+		}
+
+		var data []byte
+		if pretty {
+			data, err = json.MarshalIndent(rec, "", "  ")
+		} else {
+			data, err = json.Marshal(rec)
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+}
+
+// expand converts one record. ok is false for records that only carry
+// layout information (tabular column headers) or abbreviations (the
+// legend), and produce no output.
+func (x *Expander) expand(ce event.CompressedEvent) (rec Record, ok bool) {
+	switch ce.Name {
+	case columnsEvent:
+		x.tables.AddHeader(ce.Scope, ce.Payload)
+		return rec, false
+	case legendEvent:
+		return rec, false
+	}
+
+	rec = Record{Event: ce.Name, Scope: ce.Scope}
+	if ce.TS != 0 {
+		rec.Timestamp = ce.TS
+		rec.Time = time.UnixMilli(ce.TS).UTC().Format("2006-01-02T15:04:05.000Z07:00")
+	} else if ce.DT != nil {
+		rec.ElapsedMs = ce.DT
+	}
+
+	switch ce.Name {
+	case "getstats":
+		rec.Payload = x.expandStats(ce.Scope, ce.Payload)
+	case participantsEvent:
+		rec.Payload = expandKeys(ce.Name, ce.Payload)
+	case processor.ChunkEvent:
+		rec.Payload = x.expandChunkHeader(ce.Payload)
+	default:
+		rec.Payload = expandEvent(ce.Name, ce.Payload)
+	}
+	return rec, true
+}
+
+// expandStats renames getstats categories to their WebRTC stats types and
+// fields to their original names. Tabular rows are mapped back through
+// the announced columns.
+func (x *Expander) expandStats(scope string, payload interface{}) interface{} {
+	result, ok := payload.(map[string]interface{})
+	if !ok {
+		return payload
+	}
+
+	out := make(map[string]interface{}, len(result))
+	for key, val := range result {
+		cat, known := handlers.LookupCategory(key)
+		if !known {
+			out[key] = val
+			continue
+		}
+
+		names := make(map[string]string)
+		for _, f := range handlers.CategoryFields(key) {
+			names[f.Short] = f.Original
+		}
+
+		if v, ok := val.(string); ok && v == "=" {
+			out[cat.Name] = "unchanged"
+			continue
+		}
+		entries := x.tables.Entries(scope, key, val)
+		if entries == nil {
+			out[cat.Name] = val
+			continue
+		}
+		renamed := make([]interface{}, len(entries))
+		for i, e := range entries {
+			renamed[i] = renameStats(e, names)
+		}
+		if cat.Multi {
+			out[cat.Name] = renamed
+		} else {
+			out[cat.Name] = renamed[0]
+		}
+	}
+	return out
+}
+
+// expandChunkHeader expands the latest payloads a chunk header holds for
+// each scope, including its absolute getstats baseline.
+func (x *Expander) expandChunkHeader(payload interface{}) interface{} {
+	header, ok := payload.(map[string]interface{})
+	if !ok {
+		return payload
+	}
+	scopes, _ := header["scopes"].(map[string]interface{})
+	expanded := make(map[string]interface{}, len(scopes))
+	for scope, st := range scopes {
+		events, _ := st.(map[string]interface{})
+		out := make(map[string]interface{}, len(events))
+		for name, p := range events {
+			if name == "getstats" {
+				out[name] = x.expandStats(scope, p)
+			} else {
+				out[name] = expandEvent(name, p)
+			}
+		}
+		expanded[scope] = out
+	}
+	return map[string]interface{}{"chunk": header["n"], "scopes": expanded}
+}
+
+func renameStats(entry map[string]interface{}, names map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(entry))
+	for k, v := range entry {
+		if name, ok := names[k]; ok {
+			k = name
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// StateName returns the state a state change event's code stands for.
+// ok is false for other events and unknown codes.
+func StateName(event string, code int) (name string, ok bool) {
+	states, isState := transform.StateEvents[event]
+	if !isState {
+		return "", false
+	}
+	name = transform.StateName(states, code)
+	return name, name != ""
+}
+
+// IsStateEvent reports whether event is a state change event whose payload
+// is a bare enum code.
+func IsStateEvent(event string) bool {
+	_, ok := transform.StateEvents[event]
+	return ok
+}
+
+// expandEvent restores state names and original payload keys.
+func expandEvent(name string, payload interface{}) interface{} {
+	if IsStateEvent(name) {
+		if code, ok := payload.(float64); ok {
+			if state, ok := StateName(name, int(code)); ok {
+				return state
+			}
+		}
+		return payload
+	}
+	return expandKeys(name, payload)
+}
+
+// expandKeys renames the keys of payload, recursively, and decodes the
+// values of enum-like keys.
+func expandKeys(event string, payload interface{}) interface{} {
+	switch p := payload.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(p))
+		for k, v := range p {
+			out[KeyName(event, k)] = DecodeValue(k, expandKeys(event, v))
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(p))
+		for i, v := range p {
+			out[i] = expandKeys(event, v)
+		}
+		return out
+	default:
+		return payload
+	}
+}
+
+// KeyName returns the original name of a short payload key of event, or
+// key itself if it has none.
+func KeyName(event, key string) string {
+	if name, ok := handlers.EventPayloadKeys[event][key]; ok {
+		return name
+	}
+	if name, ok := transform.FieldNames[key]; ok {
+		return name
+	}
+	if name, ok := handlers.PayloadKeys[key]; ok {
+		return name
+	}
+	return key
+}
+
+// DecodeValue decodes the value of enum-like and boolean keys, and returns
+// any other value unchanged.
+func DecodeValue(key string, v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		if codes, ok := transform.KeyCodes[key]; ok {
+			return transform.CodeName(codes, val)
+		}
+	case float64:
+		if states, ok := transform.KeyStates[key]; ok {
+			if name := transform.StateName(states, int(val)); name != "" {
+				return name
+			}
+		}
+		if handlers.FlagKeys[key] {
+			return val != 0
+		}
+	}
+	return v
+}
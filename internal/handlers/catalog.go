@@ -0,0 +1,76 @@
+package handlers
+
+// Category describes one getstats output category.
+type Category struct {
+	Key   string // compressed key, e.g. "out_v"
+	Name  string // WebRTC stats type it is built from
+	Multi bool   // true if the category holds one entry per stream (array)
+}
+
+// Categories lists the getstats categories in output order.
+var Categories = []Category{
+	{"out_v", "outbound-rtp/video", true},
+	{"out_a", "outbound-rtp/audio", false},
+	{"in_a", "inbound-rtp/audio", false},
+	{"in_v", "inbound-rtp/video", false},
+	{"rtt", "remote-inbound-rtp", true},
+	{"cp", "candidate-pair", true},
+	{"cq", "connection-quality", false},
+	{"ms", "media-source/video", false},
+}
+
+// categoryTypes lists the report types emitted under each getstats category.
+// Categories that mix types (cp) get the union of their fields, in type order.
+var categoryTypes = map[string][]reportType{
+	"out_v": {rtOutboundVideo},
+	"out_a": {rtOutboundAudio},
+	"in_a":  {rtInboundAudio},
+	"in_v":  {rtInboundVideo},
+	"rtt":   {rtRemoteInbound},
+	"cp":    {rtCandidatePairActive, rtCandidatePairRelay},
+	"cq":    {rtConnectionQuality},
+	"ms":    {rtMediaSourceVideo},
+}
+
+// Field describes one compressed getstats field.
+type Field struct {
+	Short     string // compressed key
+	Original  string // WebRTC field name
+	IsCounter bool   // true = delta, false = gauge
+}
+
+// CategoryFields returns the fields of a getstats category in fieldSpec
+// order, or nil for an unknown category.
+func CategoryFields(category string) []Field {
+	var fields []Field
+	seen := make(map[string]bool)
+	for _, rt := range categoryTypes[category] {
+		for _, f := range fieldsForType(rt) {
+			if !seen[f.shortKey] {
+				seen[f.shortKey] = true
+				fields = append(fields, Field{Short: f.shortKey, Original: f.original, IsCounter: f.isCounter})
+			}
+		}
+	}
+	return fields
+}
+
+// CategoryColumns returns the fixed column order for a getstats category,
+// or nil for an unknown category.
+func CategoryColumns(category string) []string {
+	var cols []string
+	for _, f := range CategoryFields(category) {
+		cols = append(cols, f.Short)
+	}
+	return cols
+}
+
+// LookupCategory returns the Category with the given key.
+func LookupCategory(key string) (Category, bool) {
+	for _, c := range Categories {
+		if c.Key == key {
+			return c, true
+		}
+	}
+	return Category{}, false
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"strings"
 
 	"rtcstats/internal/event"
 	"rtcstats/internal/transform"
@@ -73,6 +74,13 @@ func (h *FailureHandler) Transform(e event.RawEvent) interface{} {
 		return map[string]interface{}{"ok": 0}
 	}
 
+	// The rtcstats client records most failures as the error's string form,
+	// "Name: message"
+	var errStr string
+	if err := json.Unmarshal(e.Payload, &errStr); err == nil {
+		return failureFromString(errStr)
+	}
+
 	var payload map[string]interface{}
 	if err := json.Unmarshal(e.Payload, &payload); err != nil {
 		return map[string]interface{}{"ok": 0}
@@ -85,12 +93,30 @@ func (h *FailureHandler) Transform(e event.RawEvent) interface{} {
 		result["errc"] = errName
 	}
 	if errMsg, ok := payload["message"].(string); ok {
-		// Truncate long messages
-		if len(errMsg) > 100 {
-			errMsg = errMsg[:100] + "..."
-		}
-		result["err"] = errMsg
+		result["err"] = truncateError(errMsg)
 	}
 
 	return result
 }
+
+// failureFromString splits an error string of the form "Name: message"
+// into errc and err. Strings without an error name are kept whole in err.
+func failureFromString(s string) map[string]interface{} {
+	result := map[string]interface{}{"ok": 0}
+	if name, msg, ok := strings.Cut(s, ": "); ok && strings.HasSuffix(name, "Error") && !strings.Contains(name, " ") {
+		result["errc"] = name
+		s = msg
+	}
+	if s != "" {
+		result["err"] = truncateError(s)
+	}
+	return result
+}
+
+// truncateError shortens long error messages.
+func truncateError(msg string) string {
+	if len(msg) > 100 {
+		return msg[:100] + "..."
+	}
+	return msg
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"math"
+	"sort"
 	"strings"
 
 	"rtcstats/internal/event"
@@ -156,6 +157,7 @@ var globalDropFields = map[string]bool{
 type StatsSnapshot struct {
 	Scope     string
 	RawValues map[string]map[string]float64 // stateKey → field → raw value
+	Slots     map[string]int                // stateKey → position in its category
 }
 
 // GetStatsHandler compresses RTCStatsReport data per the spec.
@@ -163,6 +165,8 @@ type StatsSnapshot struct {
 type GetStatsHandler struct {
 	prevValues        map[string]map[string]float64 // key: "scope:entryID" → field→value
 	lastEmittedValues map[string]map[string]float64 // baseline for emission recomputation
+	slots             map[string]int                // key: "scope:entryID" → position in its category
+	slotCounts        map[string]int                // key: "scope:category" → positions assigned
 }
 
 func (h *GetStatsHandler) Transform(e event.RawEvent) interface{} {
@@ -190,8 +194,8 @@ func (h *GetStatsHandler) Transform(e event.RawEvent) interface{} {
 	var cq map[string]interface{}
 	var ms map[string]interface{}
 
-	for entryID, raw := range payload {
-		entry, ok := raw.(map[string]interface{})
+	for _, entryID := range sortedEntryIDs(payload) {
+		entry, ok := payload[entryID].(map[string]interface{})
 		if !ok {
 			// Top-level non-dict value (e.g. "timestamp") → skip
 			continue
@@ -203,6 +207,7 @@ func (h *GetStatsHandler) Transform(e event.RawEvent) interface{} {
 		}
 
 		stateKey := scope + ":" + entryID
+		slot := h.slot(scope, stateKey, rt)
 		fields := fieldsForType(rt)
 		compressed := h.compressEntry(stateKey, entry, fields)
 		if len(compressed) == 0 {
@@ -211,7 +216,7 @@ func (h *GetStatsHandler) Transform(e event.RawEvent) interface{} {
 
 		switch rt {
 		case rtOutboundVideo:
-			outV = append(outV, compressed)
+			outV = placeEntry(outV, slot, compressed)
 		case rtOutboundAudio:
 			outA = compressed
 		case rtInboundAudio:
@@ -219,11 +224,11 @@ func (h *GetStatsHandler) Transform(e event.RawEvent) interface{} {
 		case rtInboundVideo:
 			inV = compressed
 		case rtRemoteInbound:
-			rttArr = append(rttArr, compressed)
+			rttArr = placeEntry(rttArr, slot, compressed)
 		case rtCandidatePairActive:
-			cpArr = append(cpArr, compressed)
+			cpArr = placeEntry(cpArr, slot, compressed)
 		case rtCandidatePairRelay:
-			cpArr = append(cpArr, compressed)
+			cpArr = placeEntry(cpArr, slot, compressed)
 		case rtConnectionQuality:
 			cq = compressed
 		case rtMediaSourceVideo:
@@ -284,6 +289,7 @@ func (h *GetStatsHandler) ExtractAndTransform(e event.RawEvent) (interface{}, *S
 	snapshot := &StatsSnapshot{
 		Scope:     scope,
 		RawValues: make(map[string]map[string]float64),
+		Slots:     make(map[string]int),
 	}
 
 	var outV []map[string]interface{}
@@ -295,8 +301,8 @@ func (h *GetStatsHandler) ExtractAndTransform(e event.RawEvent) (interface{}, *S
 	var cq map[string]interface{}
 	var ms map[string]interface{}
 
-	for entryID, raw := range payload {
-		entry, ok := raw.(map[string]interface{})
+	for _, entryID := range sortedEntryIDs(payload) {
+		entry, ok := payload[entryID].(map[string]interface{})
 		if !ok {
 			continue
 		}
@@ -307,6 +313,8 @@ func (h *GetStatsHandler) ExtractAndTransform(e event.RawEvent) (interface{}, *S
 		}
 
 		stateKey := scope + ":" + entryID
+		slot := h.slot(scope, stateKey, rt)
+		snapshot.Slots[stateKey] = slot
 		fields := fieldsForType(rt)
 
 		// Capture raw values into snapshot
@@ -327,7 +335,7 @@ func (h *GetStatsHandler) ExtractAndTransform(e event.RawEvent) (interface{}, *S
 
 		switch rt {
 		case rtOutboundVideo:
-			outV = append(outV, compressed)
+			outV = placeEntry(outV, slot, compressed)
 		case rtOutboundAudio:
 			outA = compressed
 		case rtInboundAudio:
@@ -335,11 +343,11 @@ func (h *GetStatsHandler) ExtractAndTransform(e event.RawEvent) (interface{}, *S
 		case rtInboundVideo:
 			inV = compressed
 		case rtRemoteInbound:
-			rttArr = append(rttArr, compressed)
+			rttArr = placeEntry(rttArr, slot, compressed)
 		case rtCandidatePairActive:
-			cpArr = append(cpArr, compressed)
+			cpArr = placeEntry(cpArr, slot, compressed)
 		case rtCandidatePairRelay:
-			cpArr = append(cpArr, compressed)
+			cpArr = placeEntry(cpArr, slot, compressed)
 		case rtConnectionQuality:
 			cq = compressed
 		case rtMediaSourceVideo:
@@ -396,7 +404,14 @@ func (h *GetStatsHandler) RecomputeForEmission(snapshot *StatsSnapshot) interfac
 	var cq map[string]interface{}
 	var ms map[string]interface{}
 
-	for stateKey, rawVals := range snapshot.RawValues {
+	stateKeys := make([]string, 0, len(snapshot.RawValues))
+	for stateKey := range snapshot.RawValues {
+		stateKeys = append(stateKeys, stateKey)
+	}
+	sort.Strings(stateKeys)
+
+	for _, stateKey := range stateKeys {
+		rawVals := snapshot.RawValues[stateKey]
 		// Extract entryID from stateKey (scope:entryID)
 		entryID := stateKey
 		if idx := strings.LastIndex(stateKey, ":"); idx >= 0 {
@@ -453,10 +468,11 @@ func (h *GetStatsHandler) RecomputeForEmission(snapshot *StatsSnapshot) interfac
 		if len(compressed) == 0 {
 			continue
 		}
+		slot := snapshot.Slots[stateKey]
 
 		switch rt {
 		case rtOutboundVideo:
-			outV = append(outV, compressed)
+			outV = placeEntry(outV, slot, compressed)
 		case rtOutboundAudio:
 			outA = compressed
 		case rtInboundAudio:
@@ -464,11 +480,11 @@ func (h *GetStatsHandler) RecomputeForEmission(snapshot *StatsSnapshot) interfac
 		case rtInboundVideo:
 			inV = compressed
 		case rtRemoteInbound:
-			rttArr = append(rttArr, compressed)
+			rttArr = placeEntry(rttArr, slot, compressed)
 		case rtCandidatePairActive:
-			cpArr = append(cpArr, compressed)
+			cpArr = placeEntry(cpArr, slot, compressed)
 		case rtCandidatePairRelay:
-			cpArr = append(cpArr, compressed)
+			cpArr = placeEntry(cpArr, slot, compressed)
 		case rtConnectionQuality:
 			cq = compressed
 		case rtMediaSourceVideo:
@@ -523,6 +539,144 @@ func (h *GetStatsHandler) UpdateEmittedBaseline(snapshot *StatsSnapshot) {
 	}
 }
 
+// ResetScope forgets the delta state of a raw scope, so its next sample is
+// compressed like a first one. Use it when the scope's peer connection is
+// replaced.
+func (h *GetStatsHandler) ResetScope(scope string) {
+	prefix := scope + ":"
+	for _, values := range []map[string]map[string]float64{h.prevValues, h.lastEmittedValues} {
+		for stateKey := range values {
+			if strings.HasPrefix(stateKey, prefix) {
+				delete(values, stateKey)
+			}
+		}
+	}
+	for _, positions := range []map[string]int{h.slots, h.slotCounts} {
+		for key := range positions {
+			if strings.HasPrefix(key, prefix) {
+				delete(positions, key)
+			}
+		}
+	}
+}
+
+// slot returns the position of an entry in its multi-entry category. Entries
+// get positions in entry ID order when first seen and keep them until
+// ResetScope; a sample without an entry leaves an empty entry ({}) at its
+// position, so the entries after it never shift.
+func (h *GetStatsHandler) slot(scope, stateKey string, rt reportType) int {
+	if h.slots == nil {
+		h.slots = make(map[string]int)
+		h.slotCounts = make(map[string]int)
+	}
+	if slot, ok := h.slots[stateKey]; ok {
+		return slot
+	}
+	countKey := scope + ":" + categoryOf(rt)
+	slot := h.slotCounts[countKey]
+	h.slotCounts[countKey] = slot + 1
+	h.slots[stateKey] = slot
+	return slot
+}
+
+// placeEntry puts entry at position slot of list, padding the positions
+// before it with empty entries.
+func placeEntry(list []map[string]interface{}, slot int, entry map[string]interface{}) []map[string]interface{} {
+	for len(list) <= slot {
+		list = append(list, map[string]interface{}{})
+	}
+	list[slot] = entry
+	return list
+}
+
+// categoryOf returns the key of the getstats category holding rt entries.
+func categoryOf(rt reportType) string {
+	for _, c := range Categories {
+		for _, t := range categoryTypes[c.Key] {
+			if t == rt {
+				return c.Key
+			}
+		}
+	}
+	return ""
+}
+
+// Baseline returns the absolute values of the entries in snapshot, laid
+// out like a compressed payload, or nil if it has none. The counter deltas
+// of the sample emitted after snapshot's are relative to these values, so
+// a reader can start from them without any earlier output.
+func Baseline(snapshot *StatsSnapshot) map[string]interface{} {
+	if snapshot == nil {
+		return nil
+	}
+	stateKeys := make([]string, 0, len(snapshot.RawValues))
+	for stateKey := range snapshot.RawValues {
+		stateKeys = append(stateKeys, stateKey)
+	}
+	sort.Strings(stateKeys)
+
+	result := make(map[string]interface{})
+	for _, stateKey := range stateKeys {
+		rawVals := snapshot.RawValues[stateKey]
+		entryID := stateKey[strings.LastIndex(stateKey, ":")+1:]
+		fakeEntry := make(map[string]interface{}, len(rawVals))
+		for k, v := range rawVals {
+			fakeEntry[k] = v
+		}
+		rt := classifyEntry(entryID, fakeEntry)
+		if rt == rtUnknown {
+			continue
+		}
+
+		entry := make(map[string]interface{})
+		for _, f := range fieldsForType(rt) {
+			if v, ok := rawVals[f.original]; ok {
+				if rounded := roundFloat(v, 6); rounded != 0 {
+					entry[f.shortKey] = cleanNumber(rounded)
+				}
+			}
+		}
+		if len(entry) > 0 {
+			addEntry(result, rt, snapshot.Slots[stateKey], entry)
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// addEntry adds a compressed entry to its category of result, at position
+// slot in multi-entry categories.
+func addEntry(result map[string]interface{}, rt reportType, slot int, entry map[string]interface{}) {
+	for _, c := range Categories {
+		for _, t := range categoryTypes[c.Key] {
+			if t != rt {
+				continue
+			}
+			if c.Multi {
+				list, _ := result[c.Key].([]map[string]interface{})
+				result[c.Key] = placeEntry(list, slot, entry)
+			} else {
+				result[c.Key] = entry
+			}
+			return
+		}
+	}
+}
+
+// sortedEntryIDs returns the entry IDs of a stats report in sorted order.
+// Multi-entry categories (out_v, rtt, cp) number their entries in this order
+// when first seen (see slot), so positions are the same between runs.
+func sortedEntryIDs(report map[string]interface{}) []string {
+	ids := make([]string, 0, len(report))
+	for id := range report {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
 // classifyEntry determines the report type of a stats entry by field fingerprint.
 // Priority is applied top-to-bottom per the spec.
 func classifyEntry(entryID string, entry map[string]interface{}) reportType {
@@ -658,8 +812,8 @@ func (h *GetStatsHandler) compressEntry(stateKey string, entry map[string]interf
 			continue
 		}
 
+		curr[f.original] = val
 		if f.isCounter {
-			curr[f.original] = val
 			if prev != nil {
 				if prevVal, hasPrev := prev[f.original]; hasPrev {
 					delta := val - prevVal
@@ -5,10 +5,15 @@ import (
 
 	"rtcstats/internal/event"
 	"rtcstats/internal/ice"
+	"rtcstats/internal/transform"
 )
 
 // OnIceCandidateHandler handles onicecandidate events
-type OnIceCandidateHandler struct{}
+type OnIceCandidateHandler struct {
+	// Detail keeps the candidate's type, transport and mid instead of
+	// only counting it.
+	Detail bool
+}
 
 func (h *OnIceCandidateHandler) Transform(e event.RawEvent) interface{} {
 	// Check for null payload (end of candidates)
@@ -30,17 +35,39 @@ func (h *OnIceCandidateHandler) Transform(e event.RawEvent) interface{} {
 		return ice.EOCSummary()
 	}
 
+	if h.Detail {
+		return ice.ParseCandidateFromPayload(payload).ToMap()
+	}
+
 	// Return simple count - candidate details are stripped
 	return ice.SimpleSummary()
 }
 
 // AddIceCandidateHandler handles addIceCandidate events
-type AddIceCandidateHandler struct{}
+type AddIceCandidateHandler struct {
+	// Detail keeps the candidate's type, transport and mid instead of
+	// only counting it.
+	Detail bool
+}
 
 func (h *AddIceCandidateHandler) Transform(e event.RawEvent) interface{} {
-	// addIceCandidate is typically an array with one candidate
-	// Just return a count
-	return ice.SimpleSummary()
+	if !h.Detail {
+		// addIceCandidate is typically an array with one candidate
+		// Just return a count
+		return ice.SimpleSummary()
+	}
+
+	var payload interface{}
+	if err := json.Unmarshal(e.Payload, &payload); err != nil {
+		return ice.SimpleSummary()
+	}
+	if arr, ok := payload.([]interface{}); ok {
+		if len(arr) == 0 {
+			return ice.SimpleSummary()
+		}
+		payload = arr[0]
+	}
+	return ice.ParseCandidateFromPayload(payload).ToMap()
 }
 
 // IceTrickleHandler handles IceTrickle events (SFU-side)
@@ -83,9 +110,10 @@ func (h *IceTrickleHandler) Transform(e event.RawEvent) interface{} {
 	return result
 }
 
-// shortenID truncates UUIDs to first 8 chars
+// shortenID truncates UUIDs to their first and last 4 chars. Pseudonym
+// aliases are kept whole, so they still match the alias table.
 func shortenID(id string) string {
-	if len(id) > 12 {
+	if len(id) > 12 && !transform.IsSaltedAlias(id) {
 		return id[:4] + ".." + id[len(id)-4:]
 	}
 	return id
@@ -0,0 +1,60 @@
+package handlers
+
+// PayloadKeys names the short payload keys that event handlers write
+// directly, i.e. those not covered by transform.FieldMap.
+var PayloadKeys = map[string]string{
+	"ok":          "success",
+	"errc":        "errorName",
+	"err":         "errorMessage",
+	"t":           "type",
+	"n":           "count",
+	"eoc":         "endOfCandidates",
+	"fr":          "fastReconnect",
+	"cap":         "capabilities",
+	"bp":          "bundlePolicy",
+	"ice":         "iceServers",
+	"st":          "state",
+	"hl":          "hasLabels",
+	"ai":          "audioinput",
+	"vi":          "videoinput",
+	"ao":          "audiooutput",
+	"a":           "audio",
+	"v":           "video",
+	"agc":         "autoGainControl",
+	"ns":          "noiseSuppression",
+	"ec":          "echoCancellation",
+	"br":          "browser",
+	"sdk":         "sdkVersion",
+	"os":          "operatingSystem",
+	"q":           "quality",
+	"u":           "userId",
+	"wh":          "dimension",
+	"tr":          "tracks",
+	"sc":          "layers",
+	"c":           "codec",
+	"s":           "ssrc",
+	"sdp_sum":     "sdp",
+	"pub_sdp_sum": "publisherSdp",
+	"sub_sdp_sum": "subscriberSdp",
+	"src":         "source",
+	"rid":         "requestId",
+	"dur":         "durationMs",
+	"req":         "request",
+	"rep":         "repeatCount",
+	"span":        "spanMs",
+	"cand":        "candidatesByType",
+	"r":           "reason",
+	"prev":        "previousScope",
+	"gap":         "gapMs",
+}
+
+// EventPayloadKeys overrides PayloadKeys for events that reuse a short key
+// with another meaning.
+var EventPayloadKeys = map[string]map[string]string{
+	"IceTrickle":      {"c": "iceCandidate", "tr": "protocol"},
+	"onicecandidate":  {"tr": "protocol"},
+	"addIceCandidate": {"tr": "protocol"},
+}
+
+// FlagKeys are the payload keys that hold 1/0 flags.
+var FlagKeys = map[string]bool{"ok": true, "fr": true, "hl": true, "eoc": true}
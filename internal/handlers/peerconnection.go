@@ -22,17 +22,7 @@ func (h *CreatePCHandler) Transform(e event.RawEvent) interface{} {
 
 	// Bundle policy
 	if bp, ok := payload["bundlePolicy"].(string); ok {
-		// Shorten common values
-		switch bp {
-		case "max-bundle":
-			result["bp"] = "mb"
-		case "max-compat":
-			result["bp"] = "mc"
-		case "balanced":
-			result["bp"] = "b"
-		default:
-			result["bp"] = bp
-		}
+		result["bp"] = transform.CompressBundlePolicy(bp)
 	}
 
 	// ICE servers summary
@@ -122,23 +122,30 @@ func (r *Registry) Get(name string) Handler {
 		return h
 	}
 
-	// Try prefix matches
-	for prefix, h := range r.prefix {
-		if strings.HasPrefix(name, prefix) {
-			return h
-		}
+	// Try prefix matches, then suffix matches; the longest match wins
+	if h := longestMatch(r.prefix, name, strings.HasPrefix); h != nil {
+		return h
 	}
-
-	// Try suffix matches
-	for suffix, h := range r.suffix {
-		if strings.HasSuffix(name, suffix) {
-			return h
-		}
+	if h := longestMatch(r.suffix, name, strings.HasSuffix); h != nil {
+		return h
 	}
 
 	return r.fallback
 }
 
+// longestMatch returns the handler of the longest pattern that matches
+// name, so overlapping patterns resolve the same way on every run.
+func longestMatch(patterns map[string]Handler, name string, match func(s, pattern string) bool) Handler {
+	var best Handler
+	bestLen := -1
+	for pattern, h := range patterns {
+		if match(name, pattern) && len(pattern) > bestLen {
+			best, bestLen = h, len(pattern)
+		}
+	}
+	return best
+}
+
 // GetStatsHandler returns the typed GetStatsHandler for direct access.
 func (r *Registry) GetStatsHandler() *GetStatsHandler {
 	return r.gsHandler
@@ -0,0 +1,152 @@
+package handlers
+
+// Tabulator converts compressed getstats payloads into positional rows.
+// The column order of each (scope, category) series is announced once via
+// the header returned by Tabulate; later samples carry only values.
+type Tabulator struct {
+	announced map[string]bool // scope + "\x00" + category
+}
+
+// NewTabulator creates a Tabulator with no announced series.
+func NewTabulator() *Tabulator {
+	return &Tabulator{announced: make(map[string]bool)}
+}
+
+// Tabulate rewrites each category of payload as a value array (or an array
+// of arrays for multi-entry categories such as out_v). Fields omitted by the
+// compressor become 0 and trailing zero columns are trimmed. Categories
+// replaced by "=" are left as is. header maps each category seen for the
+// first time in scope to its columns, and is nil when there is none.
+func (t *Tabulator) Tabulate(scope string, payload interface{}) (header map[string][]string, rows interface{}) {
+	result, ok := payload.(map[string]interface{})
+	if !ok {
+		return nil, payload
+	}
+
+	out := make(map[string]interface{}, len(result))
+	for cat, val := range result {
+		cols := CategoryColumns(cat)
+		if cols == nil {
+			out[cat] = val
+			continue
+		}
+
+		switch v := val.(type) {
+		case map[string]interface{}:
+			out[cat] = tabulateRow(v, cols)
+		case []map[string]interface{}:
+			table := make([][]interface{}, len(v))
+			for i, entry := range v {
+				table[i] = tabulateRow(entry, cols)
+			}
+			out[cat] = table
+		default:
+			out[cat] = val
+			continue
+		}
+
+		key := scope + "\x00" + cat
+		if !t.announced[key] {
+			t.announced[key] = true
+			if header == nil {
+				header = make(map[string][]string)
+			}
+			header[cat] = cols
+		}
+	}
+
+	return header, out
+}
+
+// tabulateRow lays out entry's values in column order.
+func tabulateRow(entry map[string]interface{}, cols []string) []interface{} {
+	row := make([]interface{}, len(cols))
+	n := 0
+	for i, col := range cols {
+		if v, ok := entry[col]; ok {
+			row[i] = v
+			n = i + 1
+		} else {
+			row[i] = 0
+		}
+	}
+	return row[:n]
+}
+
+// Detabulator reverses Tabulator: it tracks the columns announced by
+// header records and turns value rows back into field maps.
+type Detabulator struct {
+	columns map[string][]string // scope + "\x00" + category → columns
+}
+
+// NewDetabulator creates a Detabulator with no known columns.
+func NewDetabulator() *Detabulator {
+	return &Detabulator{columns: make(map[string][]string)}
+}
+
+// AddHeader records the columns of a header record's payload.
+func (d *Detabulator) AddHeader(scope string, payload interface{}) {
+	header, _ := payload.(map[string]interface{})
+	for cat, v := range header {
+		list, _ := v.([]interface{})
+		cols := make([]string, 0, len(list))
+		for _, col := range list {
+			if s, ok := col.(string); ok {
+				cols = append(cols, s)
+			}
+		}
+		d.columns[scope+"\x00"+cat] = cols
+	}
+}
+
+// Entry returns v as a field map. Maps are returned unchanged; value rows
+// are mapped through the columns announced for (scope, category), or the
+// default CategoryColumns if none were. Zero values are dropped, as the
+// compressor omits them.
+func (d *Detabulator) Entry(scope, category string, v interface{}) (map[string]interface{}, bool) {
+	switch e := v.(type) {
+	case map[string]interface{}:
+		return e, true
+	case []interface{}:
+		cols := d.columns[scope+"\x00"+category]
+		if cols == nil {
+			cols = CategoryColumns(category)
+		}
+		m := make(map[string]interface{}, len(e))
+		for i, val := range e {
+			if i >= len(cols) {
+				break
+			}
+			if n, ok := val.(float64); ok && n == 0 {
+				continue
+			}
+			m[cols[i]] = val
+		}
+		return m, true
+	default:
+		return nil, false
+	}
+}
+
+// Entries returns the entries of a category value: one for single-entry
+// categories, one per element for multi-entry ones (out_v, rtt, cp).
+// It returns nil for "=" and other non-entry values.
+func (d *Detabulator) Entries(scope, category string, v interface{}) []map[string]interface{} {
+	cat, ok := LookupCategory(category)
+	if !ok {
+		return nil
+	}
+	if list, isList := v.([]interface{}); isList && cat.Multi {
+		entries := make([]map[string]interface{}, 0, len(list))
+		for _, e := range list {
+			if m, ok := d.Entry(scope, category, e); ok {
+				entries = append(entries, m)
+			}
+		}
+		return entries
+	}
+	if m, ok := d.Entry(scope, category, v); ok {
+		return []map[string]interface{}{m}
+	}
+	return nil
+}
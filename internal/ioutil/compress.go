@@ -0,0 +1,96 @@
+package ioutil
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression identifies the container format of an input or output stream.
+type Compression int
+
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+	CompressionZstd
+)
+
+func (c Compression) String() string {
+	switch c {
+	case CompressionGzip:
+		return "gzip"
+	case CompressionZstd:
+		return "zstd"
+	default:
+		return "none"
+	}
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// NewDecompressReader sniffs the magic bytes at the start of r and returns a
+// reader yielding decompressed data. Uncompressed input is passed through.
+func NewDecompressReader(r io.Reader) (io.ReadCloser, Compression, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, CompressionNone, err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, CompressionGzip, fmt.Errorf("opening gzip stream: %w", err)
+		}
+		return gz, CompressionGzip, nil
+	case bytes.HasPrefix(head, zstdMagic):
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, CompressionZstd, fmt.Errorf("opening zstd stream: %w", err)
+		}
+		return zr.IOReadCloser(), CompressionZstd, nil
+	default:
+		return io.NopCloser(br), CompressionNone, nil
+	}
+}
+
+// CompressionForPath picks an output compression from a file extension
+// (".gz" or ".zst").
+func CompressionForPath(path string) Compression {
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		return CompressionGzip
+	case strings.HasSuffix(path, ".zst"):
+		return CompressionZstd
+	default:
+		return CompressionNone
+	}
+}
+
+// NewCompressWriter wraps w so that written data is compressed with c.
+// Close must be called to flush the stream; it does not close w.
+func NewCompressWriter(w io.Writer, c Compression) (io.WriteCloser, error) {
+	switch c {
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	default:
+		return nopWriteCloser{w}, nil
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
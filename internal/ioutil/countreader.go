@@ -0,0 +1,15 @@
+package ioutil
+
+import "io"
+
+// CountReader wraps an io.Reader and counts bytes read.
+type CountReader struct {
+	R     io.Reader
+	Count int64
+}
+
+func (cr *CountReader) Read(p []byte) (int, error) {
+	n, err := cr.R.Read(p)
+	cr.Count += int64(n)
+	return n, err
+}
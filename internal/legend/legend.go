@@ -0,0 +1,230 @@
+// Package legend builds a minimal dictionary for one output: only the
+// abbreviations, enum codes and scopes that actually appear in it. The
+// names come from the same tables the handlers compress with and the
+// expand package restores from.
+package legend
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"rtcstats/internal/event"
+	"rtcstats/internal/expand"
+	"rtcstats/internal/handlers"
+	"rtcstats/internal/processor"
+	"rtcstats/internal/transform"
+)
+
+// Event is the name of the record that carries a Legend.
+const Event = "legend"
+
+// Legend explains the abbreviations used in an output.
+type Legend struct {
+	Scopes    map[string]string            `json:"scopes,omitempty"`     // scope → description
+	Stats     map[string]string            `json:"stats,omitempty"`      // getstats category → WebRTC stats type
+	Fields    map[string]map[string]string `json:"fields,omitempty"`     // getstats category → short key → field name
+	Keys      map[string]string            `json:"keys,omitempty"`       // payload key → name
+	EventKeys map[string]map[string]string `json:"event_keys,omitempty"` // event → payload key → name, where it differs from Keys
+	Enums     map[string]map[string]string `json:"enums,omitempty"`      // state event or payload key → code → name
+}
+
+// Builder collects what the records of an output use.
+type Builder struct {
+	legend Legend
+}
+
+// NewBuilder creates an empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{legend: Legend{
+		Scopes:    make(map[string]string),
+		Stats:     make(map[string]string),
+		Fields:    make(map[string]map[string]string),
+		Keys:      make(map[string]string),
+		EventKeys: make(map[string]map[string]string),
+		Enums:     make(map[string]map[string]string),
+	}}
+}
+
+// Legend returns the legend of the records observed so far.
+func (b *Builder) Legend() *Legend {
+	return &b.legend
+}
+
+// Observe adds the abbreviations used by one output record.
+func (b *Builder) Observe(ce event.CompressedEvent) {
+	if ce.Scope != "" {
+		if desc := transform.DescribeScope(ce.Scope); desc != "" {
+			b.legend.Scopes[ce.Scope] = desc
+		}
+	}
+
+	switch {
+	case ce.Name == "getstats":
+		b.observeStats(ce.Payload)
+	case ce.Name == processor.ColumnsEvent:
+		// Tabular rows are only values; their fields are the columns
+		header, _ := ce.Payload.(map[string][]string)
+		for cat, cols := range header {
+			for _, col := range cols {
+				b.addField(cat, col)
+			}
+		}
+	case ce.Name == processor.ChunkEvent:
+		// Chunk headers repeat what earlier records used
+	case expand.IsStateEvent(ce.Name):
+		if code, ok := toInt(ce.Payload); ok {
+			if name, ok := expand.StateName(ce.Name, code); ok {
+				b.addEnum(ce.Name, strconv.Itoa(code), name)
+			}
+		}
+	default:
+		b.observeKeys(ce.Name, normalize(ce.Payload))
+	}
+}
+
+// normalize converts a handler payload (which may hold structs and typed
+// slices) to the generic form it takes in the output.
+func normalize(payload interface{}) interface{} {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil
+	}
+	return v
+}
+
+// observeStats adds the categories and fields of a getstats payload.
+func (b *Builder) observeStats(payload interface{}) {
+	result, ok := payload.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for key, val := range result {
+		cat, known := handlers.LookupCategory(key)
+		if !known {
+			continue
+		}
+		b.legend.Stats[key] = cat.Name
+
+		switch v := val.(type) {
+		case map[string]interface{}:
+			for field := range v {
+				b.addField(key, field)
+			}
+		case []map[string]interface{}:
+			for _, entry := range v {
+				for field := range entry {
+					b.addField(key, field)
+				}
+			}
+		}
+	}
+}
+
+// addField adds a getstats field of category cat.
+func (b *Builder) addField(cat, short string) {
+	for _, f := range handlers.CategoryFields(cat) {
+		if f.Short != short {
+			continue
+		}
+		if b.legend.Fields[cat] == nil {
+			b.legend.Fields[cat] = make(map[string]string)
+		}
+		b.legend.Fields[cat][short] = f.Original
+		return
+	}
+}
+
+// observeKeys adds the payload keys of event and the enum values they hold,
+// recursively.
+func (b *Builder) observeKeys(name string, payload interface{}) {
+	switch p := payload.(type) {
+	case map[string]interface{}:
+		for k, v := range p {
+			b.addKey(name, k)
+			b.addValue(k, v)
+			b.observeKeys(name, v)
+		}
+	case []interface{}:
+		for _, v := range p {
+			b.observeKeys(name, v)
+		}
+	}
+}
+
+// addKey adds a payload key of event if it is an abbreviation. Keys whose
+// meaning is specific to the event go to EventKeys.
+func (b *Builder) addKey(name, key string) {
+	full := expand.KeyName(name, key)
+	if full == key {
+		return
+	}
+	if general := expand.KeyName("", key); general != full {
+		if b.legend.EventKeys[name] == nil {
+			b.legend.EventKeys[name] = make(map[string]string)
+		}
+		b.legend.EventKeys[name][key] = full
+		return
+	}
+	b.legend.Keys[key] = full
+}
+
+// addValue adds an enum value of key. Booleans written as 1/0 are left out.
+func (b *Builder) addValue(key string, v interface{}) {
+	switch v.(type) {
+	case string, float64, int, int64:
+	default:
+		return
+	}
+	decoded, ok := expand.DecodeValue(key, v).(string)
+	if !ok {
+		return
+	}
+	code := fmt.Sprint(v)
+	if decoded != code {
+		b.addEnum(key, code, decoded)
+	}
+}
+
+func (b *Builder) addEnum(table, code, name string) {
+	if b.legend.Enums[table] == nil {
+		b.legend.Enums[table] = make(map[string]string)
+	}
+	b.legend.Enums[table][code] = name
+}
+
+// toInt converts a state code as produced by the handlers.
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	default:
+		return 0, false
+	}
+}
+
+// Encoder wraps an event.Encoder and feeds every record to a Builder.
+type Encoder struct {
+	enc event.Encoder
+	b   *Builder
+}
+
+// NewEncoder creates an Encoder that observes records for b before
+// passing them to enc.
+func NewEncoder(enc event.Encoder, b *Builder) *Encoder {
+	return &Encoder{enc: enc, b: b}
+}
+
+// Encode observes ce and encodes it.
+func (e *Encoder) Encode(ce event.CompressedEvent) error {
+	e.b.Observe(ce)
+	return e.enc.Encode(ce)
+}
@@ -0,0 +1,193 @@
+// Package merge interleaves events from several participant dumps into a
+// single timeline, prefixing every scope with the participant's alias.
+package merge
+
+import (
+	"container/heap"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"rtcstats/internal/event"
+)
+
+// Participant identifies one merged input.
+type Participant struct {
+	Alias  string `json:"alias"`
+	UserID string `json:"uid,omitempty"`
+	Source string `json:"src,omitempty"`
+}
+
+// Alias returns the alias for the i-th input (p1, p2, ...).
+func Alias(i int) string {
+	return "p" + strconv.Itoa(i+1)
+}
+
+// ScopeFor prefixes scope with the participant alias. Events without a
+// scope get the bare alias so they stay attributable.
+func ScopeFor(alias string, scope *string) *string {
+	s := alias
+	if scope != nil && *scope != "" {
+		s = alias + "/" + *scope
+	}
+	return &s
+}
+
+// head is the next pending event of one input.
+type head struct {
+	ev    event.RawEvent
+	input int
+}
+
+type headHeap []head
+
+func (h headHeap) Len() int { return len(h) }
+func (h headHeap) Less(i, j int) bool {
+	if h[i].ev.TS != h[j].ev.TS {
+		return h[i].ev.TS < h[j].ev.TS
+	}
+	return h[i].input < h[j].input
+}
+func (h headHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *headHeap) Push(x interface{}) { *h = append(*h, x.(head)) }
+func (h *headHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// Source merges several event sources by timestamp. Only one pending event
+// per input is held in memory.
+type Source struct {
+	inputs  []event.Source
+	aliases []string
+	heads   headHeap
+	started bool
+	count   int
+}
+
+// NewSource creates a merged source. aliases[i] is applied to inputs[i].
+func NewSource(inputs []event.Source, aliases []string) *Source {
+	return &Source{inputs: inputs, aliases: aliases}
+}
+
+// Next returns the earliest pending event across all inputs.
+func (s *Source) Next() (event.RawEvent, error) {
+	if !s.started {
+		s.started = true
+		for i := range s.inputs {
+			if err := s.advance(i); err != nil {
+				return event.RawEvent{}, err
+			}
+		}
+	}
+
+	if s.heads.Len() == 0 {
+		return event.RawEvent{}, io.EOF
+	}
+
+	h := heap.Pop(&s.heads).(head)
+	if err := s.advance(h.input); err != nil {
+		return event.RawEvent{}, err
+	}
+
+	s.count++
+	return h.ev, nil
+}
+
+// Count returns the number of events returned so far.
+func (s *Source) Count() int {
+	return s.count
+}
+
+// advance pulls the next event from input i onto the heap.
+func (s *Source) advance(i int) error {
+	ev, err := s.inputs[i].Next()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ev.Scope = ScopeFor(s.aliases[i], ev.Scope)
+	heap.Push(&s.heads, head{ev: ev, input: i})
+	return nil
+}
+
+// FindUserID scans src for the local participant's user ID, taken from the
+// joinRequest token or an outbound sfu.track.mapping. It stops at the first
+// match and returns "" if none is found.
+func FindUserID(src event.Source) string {
+	for {
+		ev, err := src.Next()
+		if err != nil {
+			return ""
+		}
+		switch ev.Name {
+		case "joinRequest":
+			if uid := userIDFromJoinRequest(ev.Payload); uid != "" {
+				return uid
+			}
+		case "sfu.track.mapping":
+			if uid := userIDFromTrackMapping(ev.Payload); uid != "" {
+				return uid
+			}
+		}
+	}
+}
+
+func userIDFromJoinRequest(data json.RawMessage) string {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return ""
+	}
+	req := payload
+	if rp, ok := payload["requestPayload"].(map[string]interface{}); ok {
+		if jr, ok := rp["joinRequest"].(map[string]interface{}); ok {
+			req = jr
+		}
+	}
+	if token, ok := req["token"].(string); ok {
+		return userIDFromToken(token)
+	}
+	return ""
+}
+
+// userIDFromToken reads the user_id claim from a JWT without verifying it.
+func userIDFromToken(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return ""
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return ""
+	}
+	if uid, ok := claims["user_id"].(string); ok {
+		return uid
+	}
+	return ""
+}
+
+func userIDFromTrackMapping(data json.RawMessage) string {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return ""
+	}
+	if dir, _ := payload["direction"].(string); dir != "outbound" {
+		return ""
+	}
+	if part, ok := payload["participant"].(map[string]interface{}); ok {
+		if uid, ok := part["user_id"].(string); ok {
+			return uid
+		}
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package processor
+
+import (
+	"strings"
+
+	"rtcstats/internal/event"
+	"rtcstats/internal/handlers"
+	"rtcstats/internal/transform"
+)
+
+// Chunking splits the output into chunks that can each be read on their
+// own. Every chunk starts with a ChunkEvent header record.
+type Chunking struct {
+	Limit int                                // maximum chunk size, in Size units
+	Size  func(ce event.CompressedEvent) int // size of one encoded record, e.g. its tokens
+	Start func(index int) error              // called before chunk index (from 1) is written
+}
+
+// ChunkEvent is the header record that starts every chunk. Its payload
+// holds the chunk number and, per active scope, the latest payload of each
+// state-bearing event plus a full getstats baseline.
+const ChunkEvent = "chunk.state"
+
+// chunkState tracks what a chunk header must repeat: the scopes seen so
+// far (until their "close" event), their connection states, the SDP
+// digests they last carried and their latest getstats sample.
+type chunkState struct {
+	scopes map[string]map[string]interface{}  // scope → event name → latest payload
+	stats  map[string]*handlers.StatsSnapshot // scope → latest written getstats sample
+}
+
+func newChunkState() *chunkState {
+	return &chunkState{
+		scopes: make(map[string]map[string]interface{}),
+		stats:  make(map[string]*handlers.StatsSnapshot),
+	}
+}
+
+// observe updates the state with a written record. snapshot holds the
+// values of a getstats record.
+func (s *chunkState) observe(ce event.CompressedEvent, snapshot *handlers.StatsSnapshot) {
+	if ce.Name == "close" {
+		delete(s.scopes, ce.Scope)
+		delete(s.stats, ce.Scope)
+		return
+	}
+	if ce.Name == "getstats" {
+		s.scope(ce.Scope)
+		s.stats[ce.Scope] = snapshot
+		return
+	}
+	if ce.Name == ColumnsEvent {
+		s.scope(ce.Scope)
+		return
+	}
+
+	if _, ok := transform.StateEvents[ce.Name]; ok {
+		s.scope(ce.Scope)[ce.Name] = ce.Payload
+		return
+	}
+
+	payload, _ := ce.Payload.(map[string]interface{})
+	digests := make(map[string]interface{})
+	for k, v := range payload {
+		if strings.HasSuffix(k, "sdp_sum") {
+			digests[k] = v
+		}
+	}
+	scope := s.scope(ce.Scope)
+	if len(digests) == 0 {
+		return
+	}
+	scope[ce.Name] = digests
+	// The created description is superseded once it is applied
+	if ce.Name == "setLocalDescription" {
+		delete(scope, "createOfferOnSuccess")
+		delete(scope, "createAnswerOnSuccess")
+	}
+}
+
+// skipped records a getstats sample of scope that the filter dropped. The
+// next sample written is compressed against it, so the next header's
+// baseline must hold its values.
+func (s *chunkState) skipped(scope string, snapshot *handlers.StatsSnapshot) {
+	s.scope(scope)
+	s.stats[scope] = snapshot
+}
+
+// forget drops scope, whose peer connection was replaced.
+func (s *chunkState) forget(scope string) {
+	delete(s.scopes, scope)
+	delete(s.stats, scope)
+}
+
+// scope returns the state of scope, adding it if needed.
+func (s *chunkState) scope(scope string) map[string]interface{} {
+	st, ok := s.scopes[scope]
+	if !ok {
+		st = make(map[string]interface{})
+		s.scopes[scope] = st
+	}
+	return st
+}
+
+// header builds the payload of chunk index's ChunkEvent record.
+func (s *chunkState) header(index int) map[string]interface{} {
+	scopes := make(map[string]interface{}, len(s.scopes))
+	for scope, st := range s.scopes {
+		// Events without a scope only matter if they left some state
+		if scope == "" && len(st) == 0 {
+			continue
+		}
+		entry := make(map[string]interface{}, len(st)+1)
+		for name, payload := range st {
+			entry[name] = payload
+		}
+		if baseline := handlers.Baseline(s.stats[scope]); baseline != nil {
+			entry["getstats"] = baseline
+		}
+		scopes[scope] = entry
+	}
+	return map[string]interface{}{"n": index, "scopes": scopes}
+}
@@ -0,0 +1,164 @@
+package processor
+
+import (
+	"encoding/json"
+	"sort"
+
+	"rtcstats/internal/event"
+	"rtcstats/internal/ice"
+)
+
+// Collapsing merges consecutive repeated events into one record.
+type Collapsing struct {
+	// Window is how long, in ms, a run may last. A repeat that comes later
+	// starts a new record.
+	Window int64
+
+	// ICE merges runs of ICE candidate events whatever their candidates,
+	// counting them by type and transport.
+	ICE bool
+}
+
+// candidateEvents are the events that ICE collapsing aggregates.
+var candidateEvents = map[string]bool{
+	"onicecandidate":  true,
+	"addIceCandidate": true,
+}
+
+// run is a record waiting to be written, with the repeats merged into it.
+type run struct {
+	ce    event.CompressedEvent
+	key   string // name and scope
+	fp    string // compressed payload the repeats share
+	first int64  // ts of the first event
+	last  int64  // ts of the latest repeat
+	count int
+	cands map[ice.CandidateSummary]int // ICE candidates by type and transport
+}
+
+// collapser merges consecutive records of the same name, scope and
+// payload. Only the latest record is held back, while repeats may still
+// follow it; any other record ends its run, so output order is unchanged.
+type collapser struct {
+	cfg     Collapsing
+	pending *run // nil if the latest record was written
+}
+
+func newCollapser(cfg Collapsing) *collapser {
+	return &collapser{cfg: cfg}
+}
+
+// add merges ce, an event at ts, into the pending run if it repeats it.
+// Otherwise it writes the pending run and then holds ce, or writes it too
+// if it cannot be merged.
+func (c *collapser) add(ce event.CompressedEvent, ts int64, write func(event.CompressedEvent) error) error {
+	key, fp, cand, ok := c.identify(ce)
+	if r := c.pending; ok && r != nil && r.key == key && r.fp == fp && ts-r.first <= c.cfg.Window {
+		r.count++
+		r.last = ts
+		if cand != nil {
+			r.cands[*cand]++
+		}
+		return nil
+	}
+
+	if err := c.end(write); err != nil {
+		return err
+	}
+	if !ok {
+		return write(ce)
+	}
+	r := &run{ce: ce, key: key, fp: fp, first: ts, last: ts, count: 1}
+	if cand != nil {
+		r.cands = map[ice.CandidateSummary]int{*cand: 1}
+	}
+	c.pending = r
+	return nil
+}
+
+// identify returns the run key and payload fingerprint of ce, and its
+// candidate if ICE collapsing applies to it. ok is false for records that
+// are never merged: getstats, whose values accumulate, payloads other than
+// objects, which cannot take the repeat count, and correlated requests and
+// results, whose rid must stay with them.
+func (c *collapser) identify(ce event.CompressedEvent) (key, fp string, cand *ice.CandidateSummary, ok bool) {
+	if ce.Name == "getstats" {
+		return "", "", nil, false
+	}
+	payload, isMap := ce.Payload.(map[string]interface{})
+	if ce.Payload != nil && !isMap {
+		return "", "", nil, false
+	}
+	if _, correlated := payload["rid"]; correlated {
+		return "", "", nil, false
+	}
+	key = ce.Name + "\x00" + ce.Scope
+
+	if c.cfg.ICE && candidateEvents[ce.Name] && payload["eoc"] == nil {
+		t, _ := payload["t"].(string)
+		tr, _ := payload["tr"].(string)
+		return key, "", &ice.CandidateSummary{Type: t, Transport: tr}, true
+	}
+
+	data, err := json.Marshal(ce.Payload)
+	if err != nil {
+		return "", "", nil, false
+	}
+	return key, string(data), nil, true
+}
+
+// end writes the pending run, if any.
+func (c *collapser) end(write func(event.CompressedEvent) error) error {
+	r := c.pending
+	if r == nil {
+		return nil
+	}
+	c.pending = nil
+	return write(r.record())
+}
+
+// record returns the run as one record. A repeated event gets rep, the
+// number of events, and span, the ms from the first to the last. An ICE
+// run gets n, its number of candidates, and cand, their counts by type and
+// transport, instead of its first candidate.
+func (r *run) record() event.CompressedEvent {
+	ce := r.ce
+	if r.cands != nil {
+		payload := map[string]interface{}{"n": r.count, "cand": candidateCounts(r.cands)}
+		if r.count > 1 {
+			payload["span"] = r.last - r.first
+		}
+		ce.Payload = payload
+		return ce
+	}
+	if r.count == 1 {
+		return ce
+	}
+
+	payload := make(map[string]interface{})
+	if p, ok := ce.Payload.(map[string]interface{}); ok {
+		for k, v := range p {
+			payload[k] = v
+		}
+	}
+	payload["rep"] = r.count
+	payload["span"] = r.last - r.first
+	ce.Payload = payload
+	return ce
+}
+
+// candidateCounts lists candidate counts sorted by type and transport.
+func candidateCounts(cands map[ice.CandidateSummary]int) []ice.CandidateSummary {
+	list := make([]ice.CandidateSummary, 0, len(cands))
+	for c, n := range cands {
+		c.N = n
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Type != list[j].Type {
+			return list[i].Type < list[j].Type
+		}
+		return list[i].Transport < list[j].Transport
+	})
+	return list
+}
@@ -0,0 +1,193 @@
+package processor
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"rtcstats/internal/event"
+)
+
+// IncompleteEvent is the record written for a request that never got an
+// OnSuccess or OnFailure result.
+const IncompleteEvent = "request.incomplete"
+
+// requestPrefixes maps the correlated requests to the prefix of their rids.
+// setLocalDescription continues the rid of the createOffer or createAnswer
+// whose result it applies, and only gets its own when there is none.
+var requestPrefixes = map[string]string{
+	"createOffer":          "o",
+	"createAnswer":         "a",
+	"setLocalDescription":  "l",
+	"setRemoteDescription": "r",
+	"addIceCandidate":      "c",
+}
+
+// resultSuffixes are the suffixes that turn a request name into the name
+// of its results.
+var resultSuffixes = []string{"OnSuccess", "OnFailure"}
+
+// pendingRequest is a request awaiting its result.
+type pendingRequest struct {
+	name    string
+	rid     string
+	ts      int64
+	written bool // false if the filter skipped it
+}
+
+// scopeRequests is the correlation state of one scope.
+type scopeRequests struct {
+	pending map[string][]pendingRequest // by request name, oldest first
+	issued  map[string]int              // rids issued so far, by prefix
+	flow    string                      // rid of a created description not yet set locally
+}
+
+// correlator pairs the requests of each scope with their results. A peer
+// connection runs these operations one at a time in call order, so the
+// results of a request name come back in the order of its requests.
+type correlator struct {
+	scopes map[string]*scopeRequests
+	order  []string // scopes in order of first request
+}
+
+func newCorrelator() *correlator {
+	return &correlator{scopes: make(map[string]*scopeRequests)}
+}
+
+// observe records a request or pairs a result with its request, and
+// returns the rid and, for a result, the ms since its request. ok is false
+// for other events and for results whose request was never seen.
+func (c *correlator) observe(name, scope string, ts int64, written bool) (rid string, dur int64, ok bool) {
+	if prefix, isRequest := requestPrefixes[name]; isRequest {
+		s := c.scope(scope)
+		rid = s.flow
+		if name != "setLocalDescription" || rid == "" {
+			s.issued[prefix]++
+			rid = prefix + strconv.Itoa(s.issued[prefix])
+		}
+		if name == "setLocalDescription" {
+			s.flow = ""
+		}
+		s.pending[name] = append(s.pending[name], pendingRequest{name: name, rid: rid, ts: ts, written: written})
+		return rid, 0, true
+	}
+
+	request, suffix := requestName(name)
+	s := c.scopes[scope]
+	if request == "" || s == nil || len(s.pending[request]) == 0 {
+		return "", 0, false
+	}
+	req := s.pending[request][0]
+	s.pending[request] = s.pending[request][1:]
+	if suffix == "OnSuccess" && (request == "createOffer" || request == "createAnswer") {
+		s.flow = req.rid
+	}
+	return req.rid, ts - req.ts, true
+}
+
+// annotate adds rid and dur to the payload of a request or result. Only
+// map payloads (or none) can take them; others are left as they are.
+func (c *correlator) annotate(ce *event.CompressedEvent, ts int64, written bool) {
+	rid, dur, ok := c.observe(ce.Name, ce.Scope, ts, written)
+	if !ok {
+		return
+	}
+	var payload map[string]interface{}
+	switch p := ce.Payload.(type) {
+	case nil:
+		payload = make(map[string]interface{})
+	case map[string]interface{}:
+		payload = p
+	default:
+		return
+	}
+	payload["rid"] = rid
+	if _, isRequest := requestPrefixes[ce.Name]; !isRequest {
+		payload["dur"] = dur
+	}
+	ce.Payload = payload
+}
+
+// incomplete removes the requests still pending in scopes and returns an
+// IncompleteEvent for each written one, oldest first. dur is the ms from
+// the request until ts.
+func (c *correlator) incomplete(scopes []string, ts int64) []event.CompressedEvent {
+	var records []event.CompressedEvent
+	for _, name := range scopes {
+		s := c.scopes[name]
+		if s == nil {
+			continue
+		}
+		var pending []pendingRequest
+		for _, reqs := range s.pending {
+			pending = append(pending, reqs...)
+		}
+		s.pending = make(map[string][]pendingRequest)
+		sort.Slice(pending, func(i, j int) bool { return pending[i].ts < pending[j].ts })
+		for _, req := range pending {
+			if !req.written {
+				continue
+			}
+			records = append(records, event.CompressedEvent{
+				Name:  IncompleteEvent,
+				Scope: name,
+				Payload: map[string]interface{}{
+					"rid": req.rid,
+					"req": req.name,
+					"dur": ts - req.ts,
+				},
+			})
+		}
+	}
+	return records
+}
+
+func (c *correlator) scope(name string) *scopeRequests {
+	s := c.scopes[name]
+	if s == nil {
+		s = &scopeRequests{
+			pending: make(map[string][]pendingRequest),
+			issued:  make(map[string]int),
+		}
+		c.scopes[name] = s
+		c.order = append(c.order, name)
+	}
+	return s
+}
+
+// requestName splits a result event name into its request and suffix, or
+// returns "" if name is not the result of a correlated request.
+func requestName(name string) (string, string) {
+	for _, suffix := range resultSuffixes {
+		request := strings.TrimSuffix(name, suffix)
+		if _, ok := requestPrefixes[request]; ok && request != name {
+			return request, suffix
+		}
+	}
+	return "", ""
+}
+
+// emitCorrelated adds rid and dur to ce and writes it. When ce closes its
+// scope's peer connection, the requests still pending there are flagged
+// right after it.
+func (p *Pipeline) emitCorrelated(ce event.CompressedEvent, ts int64) error {
+	p.correlator.annotate(&ce, ts, true)
+	if err := p.emit(ce); err != nil {
+		return err
+	}
+	if ce.Name == "close" {
+		return p.emitIncomplete([]string{ce.Scope}, ts)
+	}
+	return nil
+}
+
+// emitIncomplete flags the requests still pending in scopes as of ts.
+func (p *Pipeline) emitIncomplete(scopes []string, ts int64) error {
+	for _, ce := range p.correlator.incomplete(scopes, ts) {
+		p.setTS(&ce, ts)
+		if err := p.emit(ce); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"rtcstats/internal/event"
+	"rtcstats/internal/transform"
+)
+
+// EventInfo describes an input event to a Config.Filter.
+type EventInfo struct {
+	Name    string
+	Scope   string // compressed, as in the output ("0-pub", "sfu:frankfurt")
+	TS      int64  // epoch ms
+	Elapsed int64  // ms since the first input event
+}
+
+// skip handles an event rejected by the filter. getstats samples leave
+// state behind: the handler still compresses them, so the next emitted
+// sample's counter deltas start from the skipped one, not from the last
+// sample written. When sampling, the scope's buffered samples are flushed
+// first and the skipped sample becomes the emission baseline. When
+// chunking, it also becomes the scope's chunk header baseline.
+//
+// When correlating, skipped requests and results are still paired, so the
+// written side keeps its rid and dur. Skipped requests are never flagged
+// as incomplete, and a skipped close drops its scope's pending requests.
+func (p *Pipeline) skip(raw event.RawEvent) error {
+	if raw.Name != "getstats" {
+		if p.correlator != nil {
+			scope := transform.CompressScope(raw.Scope)
+			p.correlator.observe(raw.Name, scope, raw.TS, false)
+			if raw.Name == "close" {
+				p.correlator.incomplete([]string{scope}, raw.TS)
+			}
+		}
+		return nil
+	}
+	if p.sampler == nil && p.chunkState == nil {
+		p.gsHandler.Transform(raw)
+		return nil
+	}
+
+	scope := transform.CompressScope(raw.Scope)
+	_, snapshot := p.gsHandler.ExtractAndTransform(raw)
+	if p.sampler != nil {
+		p.sampler.FlushScope(scope)
+		if p.writeErr != nil {
+			return p.writeErr
+		}
+	}
+	if snapshot == nil {
+		return nil
+	}
+	if p.sampler != nil {
+		p.gsHandler.UpdateEmittedBaseline(snapshot)
+	}
+	if p.chunkState != nil {
+		p.chunkState.skipped(scope, snapshot)
+	}
+	return nil
+}
+
+// eventInfo describes raw for the filter.
+func (p *Pipeline) eventInfo(raw event.RawEvent) EventInfo {
+	return EventInfo{
+		Name:    raw.Name,
+		Scope:   transform.CompressScope(raw.Scope),
+		TS:      raw.TS,
+		Elapsed: raw.TS - p.firstTS,
+	}
+}
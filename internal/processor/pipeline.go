@@ -6,13 +6,54 @@ import (
 	"rtcstats/internal/event"
 	"rtcstats/internal/handlers"
 	"rtcstats/internal/sampling"
+	"rtcstats/internal/statsdelta"
 	"rtcstats/internal/transform"
 )
 
+// Config controls pipeline behavior.
+type Config struct {
+	TSMode     event.TimestampMode
+	Format     event.OutputFormat
+	Sampling   *sampling.Config // nil disables adaptive sampling
+	StatsDelta statsdelta.Mode  // how to interpret client delta-compressed getstats
+	Tabular    bool             // emit getstats as column headers + value rows
+
+	// Encoder builds a custom output encoder; it overrides Format when set.
+	Encoder func(w io.Writer) event.Encoder
+
+	// Chunking splits the output into self-contained chunks; nil writes a
+	// single stream.
+	Chunking *Chunking
+
+	// Filter selects the input events to output; nil keeps all. It runs
+	// before the handlers, after client delta-compressed getstats have
+	// been expanded.
+	Filter func(e EventInfo) bool
+
+	// Register adds or replaces handlers in the pipeline's registry.
+	Register func(r *handlers.Registry)
+
+	// Correlate pairs peer connection requests with their results, adding
+	// rid and dur, and flags requests that never completed.
+	Correlate bool
+
+	// Collapsing merges repeated events into one record; nil writes every
+	// event.
+	Collapsing *Collapsing
+
+	// Pseudonymizer replaces user, session, device and group IDs with
+	// aliases before any handler sees them; nil keeps them.
+	Pseudonymizer *transform.Pseudonymizer
+}
+
+// ColumnsEvent is the header record announcing the column order of
+// tabular getstats series.
+const ColumnsEvent = "getstats.cols"
+
 // Pipeline processes RawEvents and outputs CompressedEvents
 type Pipeline struct {
-	reader      *event.Reader
-	writer      *event.Writer
+	reader      event.Source
+	writer      event.Encoder
 	registry    *handlers.Registry
 	tsMode      event.TimestampMode
 	firstTS     int64
@@ -20,19 +61,70 @@ type Pipeline struct {
 	suppressor  *sampling.SteadyStateSuppressor
 	samplingCfg *sampling.Config
 	gsHandler   *handlers.GetStatsHandler
-	writeErr    error // captures write errors from sampler callback
+	deltaDec    *statsdelta.Decoder
+	tabulator   *handlers.Tabulator // nil unless Config.Tabular
+	writeErr    error               // captures write errors from sampler callback
+	filter      func(e EventInfo) bool
+	correlator  *correlator // nil unless Config.Correlate
+	collapser   *collapser  // nil unless Config.Collapsing
+	segments    *segmenter
+	pseudonyms  *transform.Pseudonymizer // nil unless Config.Pseudonymizer
+	lastTS      int64
+
+	chunking     *Chunking
+	chunkState   *chunkState
+	chunkSizes   []int                     // size of each chunk started so far
+	chunkRecords int                       // records in the current chunk, after its header
+	statsQueue   []*handlers.StatsSnapshot // values of the getstats records emitted but not yet written
+	preamble     []event.CompressedEvent   // WriteRecord records, repeated in every chunk
 }
 
 // NewPipeline creates a new processing pipeline
-func NewPipeline(reader *event.Reader, w io.Writer, tsMode event.TimestampMode, pretty bool, samplingCfg *sampling.Config) *Pipeline {
+func NewPipeline(reader event.Source, w io.Writer, cfg Config) *Pipeline {
 	reg := handlers.NewRegistry()
+	if cfg.Collapsing != nil && cfg.Collapsing.ICE {
+		// Candidates are counted by type and transport
+		reg.Register("onicecandidate", &handlers.OnIceCandidateHandler{Detail: true})
+		reg.Register("addIceCandidate", &handlers.AddIceCandidateHandler{Detail: true})
+	}
+	if cfg.Register != nil {
+		cfg.Register(reg)
+	}
+	samplingCfg := cfg.Sampling
+	var writer event.Encoder
+	if cfg.Encoder != nil {
+		writer = cfg.Encoder(w)
+	} else {
+		writer = event.NewEncoder(w, cfg.Format)
+	}
 	p := &Pipeline{
 		reader:      reader,
-		writer:      event.NewWriter(w, pretty),
+		writer:      writer,
 		registry:    reg,
-		tsMode:      tsMode,
+		tsMode:      cfg.TSMode,
 		samplingCfg: samplingCfg,
 		gsHandler:   reg.GetStatsHandler(),
+		deltaDec:    statsdelta.NewDecoder(cfg.StatsDelta),
+		filter:      cfg.Filter,
+		segments:    newSegmenter(),
+		pseudonyms:  cfg.Pseudonymizer,
+	}
+
+	if cfg.Tabular {
+		p.tabulator = handlers.NewTabulator()
+	}
+
+	if cfg.Correlate {
+		p.correlator = newCorrelator()
+	}
+
+	if cfg.Collapsing != nil {
+		p.collapser = newCollapser(*cfg.Collapsing)
+	}
+
+	if cfg.Chunking != nil {
+		p.chunking = cfg.Chunking
+		p.chunkState = newChunkState()
 	}
 
 	if samplingCfg != nil && samplingCfg.Enabled {
@@ -45,23 +137,64 @@ func NewPipeline(reader *event.Reader, w io.Writer, tsMode event.TimestampMode,
 	return p
 }
 
-// Run processes all events
+// Run processes all events, pulling them from the reader one at a time
 func (p *Pipeline) Run() error {
-	events := p.reader.AllEvents()
+	for i := 0; ; i++ {
+		rawEvent, err := p.reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
 
-	for i, rawEvent := range events {
 		// Track first timestamp for delta calculation
 		if i == 0 {
 			p.firstTS = rawEvent.TS
 		}
+		p.lastTS = rawEvent.TS
+
+		// Stats reports carry no user, session or device IDs
+		if p.pseudonyms != nil && rawEvent.Name != "getstats" {
+			rawEvent.Payload = p.pseudonyms.Payload(rawEvent.Payload)
+		}
+
+		// A replacing peer connection starts from fresh state. The marker is
+		// written even if the filter drops rawEvent, as the state is reset
+		// either way and the next deltas of the scope are absolute.
+		marker, err := p.segment(rawEvent)
+		if err != nil {
+			return err
+		}
+		if marker != nil {
+			if err := p.emit(*marker); err != nil {
+				return err
+			}
+		}
+
+		// Expand client delta-compressed stats before classification
+		if rawEvent.Name == "getstats" {
+			rawEvent = p.deltaDec.Decode(rawEvent)
+		}
+
+		if p.filter != nil && !p.filter(p.eventInfo(rawEvent)) {
+			if err := p.skip(rawEvent); err != nil {
+				return err
+			}
+			continue
+		}
 
 		if p.sampler != nil && rawEvent.Name == "getstats" {
 			if err := p.processGetstatsWithSampling(rawEvent); err != nil {
 				return err
 			}
+		} else if p.correlator != nil {
+			if err := p.emitCorrelated(p.transformEvent(rawEvent), rawEvent.TS); err != nil {
+				return err
+			}
 		} else {
 			compressed := p.transformEvent(rawEvent)
-			if err := p.writer.Write(compressed); err != nil {
+			if err := p.emit(compressed); err != nil {
 				return err
 			}
 		}
@@ -75,9 +208,43 @@ func (p *Pipeline) Run() error {
 		}
 	}
 
+	if p.correlator != nil {
+		if err := p.emitIncomplete(p.correlator.order, p.lastTS); err != nil {
+			return err
+		}
+	}
+
+	if p.collapser != nil {
+		if err := p.collapser.end(p.emitChunk); err != nil {
+			return err
+		}
+	}
+
+	// Empty input still produces one chunk
+	if p.chunking != nil && p.chunkSizes == nil {
+		return p.startChunk(event.CompressedEvent{})
+	}
+
 	return nil
 }
 
+// WriteRecord writes a record directly to the output, bypassing handlers.
+// Use it for header records before calling Run. When chunking, the record
+// is repeated at the start of every chunk.
+func (p *Pipeline) WriteRecord(ce event.CompressedEvent) error {
+	if p.chunking != nil {
+		p.preamble = append(p.preamble, ce)
+		return nil
+	}
+	return p.writer.Encode(ce)
+}
+
+// ChunkSizes returns the size of each chunk written, as measured by
+// Chunking.Size. It is nil when not chunking.
+func (p *Pipeline) ChunkSizes() []int {
+	return p.chunkSizes
+}
+
 // processGetstatsWithSampling routes a getstats event through the sampler.
 func (p *Pipeline) processGetstatsWithSampling(raw event.RawEvent) error {
 	// Use ExtractAndTransform to get both payload and snapshot
@@ -89,17 +256,7 @@ func (p *Pipeline) processGetstatsWithSampling(raw event.RawEvent) error {
 		Scope:   transform.CompressScope(raw.Scope),
 		Payload: payload,
 	}
-	switch p.tsMode {
-	case event.TSAbsolute:
-		ce.TS = raw.TS
-	case event.TSDelta:
-		dt := raw.TS - p.firstTS
-		ce.DT = &dt
-	case event.TSBoth:
-		ce.TS = raw.TS
-		dt := raw.TS - p.firstTS
-		ce.DT = &dt
-	}
+	p.setTS(&ce, raw.TS)
 
 	// Hand to sampler — it will call emitSampledEvent when ready
 	p.sampler.ProcessGetStats(ce, payload, snapshot)
@@ -113,27 +270,155 @@ func (p *Pipeline) emitSampledEvent(ce event.CompressedEvent, snapshot *handlers
 	}
 
 	// Recompute deltas against lastEmittedValues baseline
-	recomputed := p.gsHandler.RecomputeForEmission(snapshot)
-
-	// Apply steady-state suppression if enabled
-	if p.suppressor != nil && recomputed != nil {
-		recomputed = p.suppressor.Suppress(ce.Scope, recomputed)
-	}
-
-	ce.Payload = recomputed
+	ce.Payload = p.gsHandler.RecomputeForEmission(snapshot)
 
 	// Update the emission baseline
 	p.gsHandler.UpdateEmittedBaseline(snapshot)
+	if p.chunking != nil {
+		p.statsQueue = append(p.statsQueue, snapshot)
+	}
 
-	if err := p.writer.Write(ce); err != nil {
+	if err := p.emit(ce); err != nil {
 		p.writeErr = err
 	}
 }
 
+// emit writes a transformed event, holding it back while repeats may still
+// be merged into it when collapsing.
+func (p *Pipeline) emit(ce event.CompressedEvent) error {
+	if p.collapser != nil {
+		return p.collapser.add(ce, p.eventTS(ce), p.emitChunk)
+	}
+	return p.emitChunk(ce)
+}
+
+// eventTS returns the epoch ms of a transformed event.
+func (p *Pipeline) eventTS(ce event.CompressedEvent) int64 {
+	if ce.DT != nil {
+		return p.firstTS + *ce.DT
+	}
+	return ce.TS
+}
+
+// emitChunk writes a record. When chunking, it first starts a new chunk if
+// the record does not fit in the current one.
+func (p *Pipeline) emitChunk(ce event.CompressedEvent) error {
+	if p.chunking == nil {
+		return p.write(p.suppress(ce))
+	}
+
+	// Records are written in the order they were emitted
+	var snapshot *handlers.StatsSnapshot
+	if ce.Name == "getstats" {
+		snapshot = p.statsQueue[0]
+		p.statsQueue[0] = nil
+		p.statsQueue = p.statsQueue[1:]
+	}
+
+	if p.chunkSizes == nil || p.chunkFull(ce) {
+		if ce.Name == "getstats" {
+			// The header's getstats baseline holds this sample's values
+			p.chunkState.observe(ce, snapshot)
+			return p.startChunk(ce)
+		}
+		if err := p.startChunk(ce); err != nil {
+			return err
+		}
+	}
+
+	p.chunkRecords++
+	p.chunkState.observe(ce, snapshot)
+	return p.write(p.suppress(ce))
+}
+
+// suppress applies steady-state suppression to a getstats record about to
+// be written. Doing it here rather than when the record is emitted keeps
+// "=" relative to the previous record written, even when records are
+// delayed or a chunk starts in between.
+func (p *Pipeline) suppress(ce event.CompressedEvent) event.CompressedEvent {
+	if p.suppressor != nil && ce.Name == "getstats" && ce.Payload != nil {
+		ce.Payload = p.suppressor.Suppress(ce.Scope, ce.Payload)
+	}
+	return ce
+}
+
+// chunkFull reports whether ce would take the current chunk past the
+// limit. A chunk always takes at least one record after its header.
+func (p *Pipeline) chunkFull(ce event.CompressedEvent) bool {
+	if p.chunkRecords == 0 {
+		return false
+	}
+	return p.chunkSizes[len(p.chunkSizes)-1]+p.chunking.Size(ce) > p.chunking.Limit
+}
+
+// startChunk begins a new chunk with the preamble records and a ChunkEvent
+// header, timestamped like ce, holding everything earlier chunks told the
+// reader. Tabular columns are announced again and steady-state suppression
+// starts over, so nothing in the chunk refers to earlier output.
+func (p *Pipeline) startChunk(ce event.CompressedEvent) error {
+	index := len(p.chunkSizes) + 1
+	if err := p.chunking.Start(index); err != nil {
+		return err
+	}
+	p.chunkSizes = append(p.chunkSizes, 0)
+	p.chunkRecords = 0
+
+	if p.tabulator != nil {
+		p.tabulator = handlers.NewTabulator()
+	}
+	if p.suppressor != nil {
+		p.suppressor.Reset()
+	}
+
+	for _, rec := range p.preamble {
+		if err := p.write(rec); err != nil {
+			return err
+		}
+	}
+	return p.write(event.CompressedEvent{
+		Name:    ChunkEvent,
+		TS:      ce.TS,
+		DT:      ce.DT,
+		Payload: p.chunkState.header(index),
+	})
+}
+
+// write encodes a record, laying out getstats payloads as positional rows
+// (preceded by any new column headers) in tabular mode.
+func (p *Pipeline) write(ce event.CompressedEvent) error {
+	if p.tabulator != nil && ce.Name == "getstats" {
+		header, rows := p.tabulator.Tabulate(ce.Scope, ce.Payload)
+		if header != nil {
+			cols := ce
+			cols.Name = ColumnsEvent
+			cols.Payload = header
+			if err := p.encode(cols); err != nil {
+				return err
+			}
+		}
+		ce.Payload = rows
+	}
+	return p.encode(ce)
+}
+
+// encode writes one record, adding its size to the current chunk.
+func (p *Pipeline) encode(ce event.CompressedEvent) error {
+	if p.chunking != nil {
+		p.chunkSizes[len(p.chunkSizes)-1] += p.chunking.Size(ce)
+	}
+	return p.writer.Encode(ce)
+}
+
 func (p *Pipeline) transformEvent(raw event.RawEvent) event.CompressedEvent {
-	// Get the appropriate handler
-	handler := p.registry.Get(raw.Name)
-	payload := handler.Transform(raw)
+	var payload interface{}
+	if raw.Name == "getstats" && p.chunking != nil {
+		// A chunk header may need this sample's values as its baseline
+		var snapshot *handlers.StatsSnapshot
+		payload, snapshot = p.gsHandler.ExtractAndTransform(raw)
+		p.statsQueue = append(p.statsQueue, snapshot)
+	} else {
+		payload = p.registry.Get(raw.Name).Transform(raw)
+	}
 
 	// Build compressed event
 	compressed := event.CompressedEvent{
@@ -142,18 +427,21 @@ func (p *Pipeline) transformEvent(raw event.RawEvent) event.CompressedEvent {
 		Payload: payload,
 	}
 
-	// Handle timestamps based on mode
+	p.setTS(&compressed, raw.TS)
+	return compressed
+}
+
+// setTS sets the timestamps of ce for ts according to the timestamp mode.
+func (p *Pipeline) setTS(ce *event.CompressedEvent, ts int64) {
 	switch p.tsMode {
 	case event.TSAbsolute:
-		compressed.TS = raw.TS
+		ce.TS = ts
 	case event.TSDelta:
-		dt := raw.TS - p.firstTS
-		compressed.DT = &dt
+		dt := ts - p.firstTS
+		ce.DT = &dt
 	case event.TSBoth:
-		compressed.TS = raw.TS
-		dt := raw.TS - p.firstTS
-		compressed.DT = &dt
+		ce.TS = ts
+		dt := ts - p.firstTS
+		ce.DT = &dt
 	}
-
-	return compressed
 }
@@ -0,0 +1,219 @@
+package processor
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"rtcstats/internal/event"
+	"rtcstats/internal/transform"
+)
+
+// SegmentEvent is the marker record written before the first event of a
+// peer connection that replaces an earlier one, e.g. 1-pub after 0-pub.
+const SegmentEvent = "segment.start"
+
+// Reasons a new peer connection generation starts, from the joinRequest
+// sent since the replaced connection was created.
+const (
+	ReasonFastReconnect = "fast_reconnect" // joinRequest with fastReconnect
+	ReasonMigration     = "migration"      // joinRequest to another SFU
+	ReasonRejoin        = "rejoin"         // joinRequest without fastReconnect
+	ReasonReconnect     = "reconnect"      // no joinRequest
+)
+
+// pcScopePattern matches peer connection scopes: generation and role.
+var pcScopePattern = regexp.MustCompile(`^(\d+)-(pub|sub)$`)
+
+// sfuGenPattern matches the generation number that starts SFU hostnames.
+var sfuGenPattern = regexp.MustCompile(`^\d+-`)
+
+// downStates are the connection states after which a peer connection no
+// longer carries media.
+var downStates = map[string]bool{"disconnected": true, "failed": true, "closed": true}
+
+// peerConn is the latest peer connection of one role.
+type peerConn struct {
+	scope   string
+	gen     int
+	created int64 // ts of its first event
+	last    int64 // ts of its latest event
+	down    int64 // ts it went down; 0 while up
+	create  bool  // whether its create event was seen
+}
+
+// participant tracks the peer connections and joinRequests of one merge
+// participant ("" in single dumps).
+type participant struct {
+	pcs     map[string]*peerConn // by role
+	join    int64                // ts of the latest joinRequest; 0 if none
+	fast    bool                 // whether that joinRequest asked for fastReconnect
+	sfu     string               // SFU it was sent to
+	prevSFU string               // SFU of the joinRequest before it
+}
+
+// segmenter detects peer connection generations: a <gen>-pub or <gen>-sub
+// scope with a higher generation than the one before it, or a second
+// create event in the same scope.
+type segmenter struct {
+	participants map[string]*participant
+}
+
+func newSegmenter() *segmenter {
+	return &segmenter{participants: make(map[string]*participant)}
+}
+
+// observe updates the generations with raw, whose compressed scope is
+// scope. If raw is the first event of a replacing peer connection, it
+// returns the payload of its SegmentEvent and the scope it replaces.
+func (s *segmenter) observe(raw event.RawEvent, scope string) (payload map[string]interface{}, prev string) {
+	alias, local := "", scope
+	if idx := strings.IndexByte(scope, '/'); idx > 0 {
+		alias, local = scope[:idx], scope[idx+1:]
+	}
+	pt := s.participants[alias]
+	if pt == nil {
+		pt = &participant{pcs: make(map[string]*peerConn)}
+		s.participants[alias] = pt
+	}
+
+	if raw.Name == "joinRequest" {
+		pt.observeJoin(raw)
+		return nil, ""
+	}
+
+	m := pcScopePattern.FindStringSubmatch(local)
+	if m == nil {
+		return nil, ""
+	}
+	gen, _ := strconv.Atoi(m[1])
+	role := m[2]
+
+	pc := pt.pcs[role]
+	replaces := pc != nil && (gen > pc.gen || scope == pc.scope && raw.Name == "create" && pc.create)
+	switch {
+	case pc == nil || replaces:
+		if replaces {
+			payload = map[string]interface{}{
+				"r":    pt.reason(pc),
+				"prev": pc.scope,
+				"gap":  raw.TS - pc.downSince(),
+			}
+			prev = pc.scope
+		}
+		pc = &peerConn{scope: scope, gen: gen, created: raw.TS}
+		pt.pcs[role] = pc
+	case scope != pc.scope:
+		// A late event of a replaced connection
+		return nil, ""
+	}
+
+	pc.last = raw.TS
+	switch raw.Name {
+	case "create":
+		pc.create = true
+	case "iceconnectionstatechange", "connectionstatechange":
+		var state string
+		json.Unmarshal(raw.Payload, &state)
+		if downStates[state] {
+			if pc.down == 0 {
+				pc.down = raw.TS
+			}
+		} else if state == "connected" || state == "completed" {
+			pc.down = 0
+		}
+	}
+	return payload, prev
+}
+
+// observeJoin records a joinRequest.
+func (pt *participant) observeJoin(raw event.RawEvent) {
+	sfu := ""
+	if raw.Scope != nil {
+		sfu = *raw.Scope
+		if idx := strings.IndexByte(sfu, '/'); idx > 0 {
+			sfu = sfu[idx+1:]
+		}
+		// 0-sfu-... and 1-sfu-... are the same SFU
+		sfu = sfuGenPattern.ReplaceAllString(sfu, "")
+	}
+
+	var payload struct {
+		FastReconnect  bool `json:"fastReconnect"`
+		RequestPayload struct {
+			JoinRequest struct {
+				FastReconnect bool `json:"fastReconnect"`
+			} `json:"joinRequest"`
+		} `json:"requestPayload"`
+	}
+	json.Unmarshal(raw.Payload, &payload)
+
+	if pt.join != 0 {
+		pt.prevSFU = pt.sfu
+	}
+	pt.join = raw.TS
+	pt.fast = payload.FastReconnect || payload.RequestPayload.JoinRequest.FastReconnect
+	pt.sfu = sfu
+}
+
+// reason explains why the peer connection replacing pc was created.
+func (pt *participant) reason(pc *peerConn) string {
+	switch {
+	case pt.join == 0 || pt.join < pc.created:
+		return ReasonReconnect
+	case pt.prevSFU != "" && pt.sfu != pt.prevSFU:
+		return ReasonMigration
+	case pt.fast:
+		return ReasonFastReconnect
+	default:
+		return ReasonRejoin
+	}
+}
+
+// downSince returns when pc went down, or its latest event if it never did.
+func (pc *peerConn) downSince() int64 {
+	if pc.down != 0 {
+		return pc.down
+	}
+	return pc.last
+}
+
+// segment detects whether raw starts a new peer connection generation. If
+// so, it resets the state the replaced connection left behind (getstats
+// deltas, client delta baseline, sampling and suppression state, chunk
+// header state), so no delta spans two connections. Samples the sampler
+// still held for it and its pending requests are written first. It
+// returns the SegmentEvent to write before raw, if any.
+func (p *Pipeline) segment(raw event.RawEvent) (*event.CompressedEvent, error) {
+	scope := transform.CompressScope(raw.Scope)
+	payload, prev := p.segments.observe(raw, scope)
+	if payload == nil {
+		return nil, nil
+	}
+
+	// Peer connection scopes are the same raw and compressed
+	p.gsHandler.ResetScope(prev)
+	p.deltaDec.ResetScope(prev)
+	if p.sampler != nil {
+		p.sampler.ResetScope(prev)
+		if p.writeErr != nil {
+			return nil, p.writeErr
+		}
+	}
+	if p.suppressor != nil {
+		p.suppressor.ResetScope(prev)
+	}
+	if p.chunkState != nil {
+		p.chunkState.forget(prev)
+	}
+	if p.correlator != nil {
+		if err := p.emitIncomplete([]string{prev}, raw.TS); err != nil {
+			return nil, err
+		}
+	}
+
+	marker := event.CompressedEvent{Name: SegmentEvent, Scope: scope, Payload: payload}
+	p.setTS(&marker, raw.TS)
+	return &marker, nil
+}
@@ -0,0 +1,154 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"rtcstats/internal/event"
+)
+
+func TestSegmenterObserve(t *testing.T) {
+	type ev struct {
+		name, scope, payload string
+		ts                   int64
+	}
+	const fast, rejoin = `{"fastReconnect": true}`, `{"requestPayload": {"joinRequest": {"fastReconnect": false}}}`
+	tests := []struct {
+		name   string
+		events []ev
+		want   []string // "<index> <reason> <prev> <gap>" per marker
+	}{
+		{
+			name:   "first connection",
+			events: []ev{{"create", "0-pub", `{}`, 0}, {"getstats", "0-pub", `{}`, 1000}},
+		},
+		{
+			name: "reconnect without joinRequest",
+			events: []ev{
+				{"create", "0-pub", `{}`, 0},
+				{"connectionstatechange", "0-pub", `"failed"`, 1000},
+				{"create", "1-pub", `{}`, 3000},
+			},
+			want: []string{"2 reconnect 0-pub 2000"},
+		},
+		{
+			name: "joinRequest before the replaced connection",
+			events: []ev{
+				{"joinRequest", "0-sfu-a", fast, 0},
+				{"create", "0-pub", `{}`, 100},
+				{"create", "1-pub", `{}`, 3000},
+			},
+			want: []string{"2 reconnect 0-pub 2900"},
+		},
+		{
+			name: "fast reconnect",
+			events: []ev{
+				{"create", "0-pub", `{}`, 0},
+				{"joinRequest", "0-sfu-a", fast, 2000},
+				{"create", "1-pub", `{}`, 2500},
+			},
+			want: []string{"2 fast_reconnect 0-pub 2500"},
+		},
+		{
+			name: "rejoin",
+			events: []ev{
+				{"create", "0-pub", `{}`, 0},
+				{"joinRequest", "0-sfu-a", rejoin, 2000},
+				{"create", "1-pub", `{}`, 2500},
+			},
+			want: []string{"2 rejoin 0-pub 2500"},
+		},
+		{
+			name: "migration",
+			events: []ev{
+				{"create", "0-pub", `{}`, 0},
+				{"joinRequest", "0-sfu-a", `{}`, 100},
+				{"joinRequest", "1-sfu-b", fast, 2000},
+				{"create", "1-pub", `{}`, 2500},
+			},
+			want: []string{"3 migration 0-pub 2500"},
+		},
+		{
+			name: "same SFU in a new generation",
+			events: []ev{
+				{"create", "0-pub", `{}`, 0},
+				{"joinRequest", "0-sfu-a", `{}`, 100},
+				{"joinRequest", "1-sfu-a", fast, 2000},
+				{"create", "1-pub", `{}`, 2500},
+			},
+			want: []string{"3 fast_reconnect 0-pub 2500"},
+		},
+		{
+			name: "second create in the same scope",
+			events: []ev{
+				{"create", "0-pub", `{}`, 0},
+				{"getstats", "0-pub", `{}`, 1000},
+				{"create", "0-pub", `{}`, 4000},
+			},
+			want: []string{"2 reconnect 0-pub 3000"},
+		},
+		{
+			name: "late event of a replaced connection",
+			events: []ev{
+				{"create", "0-pub", `{}`, 0},
+				{"create", "1-pub", `{}`, 1000},
+				{"getstats", "0-pub", `{}`, 1500},
+				{"getstats", "1-pub", `{}`, 2000},
+			},
+			want: []string{"1 reconnect 0-pub 1000"},
+		},
+		{
+			name: "roles are separate",
+			events: []ev{
+				{"create", "0-pub", `{}`, 0},
+				{"create", "0-sub", `{}`, 100},
+				{"create", "1-sub", `{}`, 1000},
+			},
+			want: []string{"2 reconnect 0-sub 900"},
+		},
+		{
+			name: "merge participants are separate",
+			events: []ev{
+				{"create", "p1/0-pub", `{}`, 0},
+				{"create", "p2/1-pub", `{}`, 100},
+				{"create", "p1/1-pub", `{}`, 1000},
+			},
+			want: []string{"2 reconnect p1/0-pub 1000"},
+		},
+		{
+			name: "gap starts at the last drop",
+			events: []ev{
+				{"create", "0-pub", `{}`, 0},
+				{"connectionstatechange", "0-pub", `"disconnected"`, 1000},
+				{"connectionstatechange", "0-pub", `"connected"`, 1500},
+				{"iceconnectionstatechange", "0-pub", `"failed"`, 2000},
+				{"connectionstatechange", "0-pub", `"failed"`, 2200},
+				{"create", "1-pub", `{}`, 5000},
+			},
+			want: []string{"5 reconnect 0-pub 3000"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSegmenter()
+			var got []string
+			for i, e := range tt.events {
+				scope := e.scope
+				raw := event.RawEvent{Name: e.name, Scope: &scope, Payload: json.RawMessage(e.payload), TS: e.ts}
+				payload, prev := s.observe(raw, scope)
+				if payload == nil {
+					continue
+				}
+				if payload["prev"] != prev {
+					t.Errorf("event %d: payload prev %v, returned %q", i, payload["prev"], prev)
+				}
+				got = append(got, fmt.Sprintf("%d %v %v %v", i, payload["r"], payload["prev"], payload["gap"]))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("markers = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
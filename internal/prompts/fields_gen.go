@@ -0,0 +1,12 @@
+// Code generated by go run gen.go; DO NOT EDIT.
+
+package prompts
+
+// StatsFields maps abbreviated getstats report type and field keys to their meanings.
+const StatsFields = "Δ=delta(change since last sample, omitted when 0) G=gauge(snapshot, omitted when 0/null) S=sparse(only present when non-zero)\nReport types: out_v=outbound-rtp/video out_a=outbound-rtp/audio in_a=inbound-rtp/audio in_v=inbound-rtp/video rtt=remote-inbound-rtp cp=candidate-pair cq=connection-quality ms=media-source/video\nFields: bs=bytesSent(Δ) hbs=headerBytesSent(Δ) ps=packetsSent(Δ) fe=framesEncoded(Δ) fps=framesPerSecond(G) qp=qpSum(Δ) tet=totalEncodeTime(Δ,sec) tebt=totalEncodedBytesTarget(Δ) pli=pliCount(ΔS) hfs=hugeFramesSent(ΔS) br=bytesReceived(Δ) hbr=headerBytesReceived(Δ) pr=packetsReceived(Δ) j=jitter(G,sec) al=audioLevel(G,0-1) tae=totalAudioEnergy(Δ) tsd=totalSamplesDuration(Δ,sec) tsr=totalSamplesReceived(Δ) cs=concealedSamples(ΔS) ce=concealmentEvents(ΔS) rsa=removedSamplesForAcceleration(ΔS) scs=silentConcealedSamples(ΔS) jbd=jitterBufferDelay(Δ,sec) jbe=jitterBufferEmittedCount(Δ) jbm=jitterBufferMinimumDelay(Δ,sec) jbt=jitterBufferTargetDelay(Δ,sec) fd=framesDecoded(Δ) fr=framesReceived(Δ) fam=framesAssembledFromMultiplePackets(Δ) tdt=totalDecodeTime(Δ,sec) tifd=totalInterFrameDelay(Δ,sec) tsid=totalSquaredInterFrameDelay(Δ) tat=totalAssemblyTime(Δ,sec) tpd=totalProcessingDelay(Δ,sec) pl=packetsLost(ΔS) pd=packetsDiscarded(ΔS) nk=nackCount(ΔS) kfd=keyFramesDecoded(ΔS) fzc=freezeCount(ΔS) fzd=totalFreezesDuration(ΔS,sec) fdr=framesDropped(ΔS) rtt=roundTripTime(G,sec) trtt=totalRoundTripTime(Δ,sec) rttm=roundTripTimeMeasurements(Δ) cp.rtt=currentRoundTripTime(G,sec) rr=responsesReceived(Δ) rts=remoteTimestamp(G) s=score(G,0-100) as=avgScore(G) mos=mosScore(G,1-5) f=frames(Δ)"
+
+// EventFields maps abbreviated connection event payload keys to their meanings.
+const EventFields = "Fields: a=audio agc=autoGainControl ai=audioinput ao=audiooutput bp=bundlePolicy(b=balanced,mb=max-bundle,mc=max-compat) br=browser([name,majorVersion]) c=codec cand=candidatesByType([{t=type,tr=transport,n=count}]) cap=capabilities did=deviceId dir=direction(in=inbound,out=outbound) dur=durationMs(ms) ec=echoCancellation en=enabled eoc=endOfCandidates(1/0) err=errorMessage errc=errorName fr=fastReconnect(1/0) gap=gapMs(ms since the previous connection went down) gid=groupId h=height hl=hasLabels(1/0) ice=iceServers k=kind(a=audio,v=video) mid=sdpMid mli=sdpMLineIndex mu=muted n=count ns=noiseSuppression ok=success(1/0) os=operatingSystem([name,version,arch]) prev=previousScope pt=peerType(publisher=0,subscriber=1) pub_sdp_sum=publisherSdp q=quality r=reason(fast_reconnect|migration|rejoin|reconnect) rep=repeatCount req=request rid=requestId rs=readyState s=ssrc sc=layers([[rid,kbps,width,height]]) sdk=sdkVersion([type,version]) sdp_sum=sdp(see sdp_sum fields) sid=sessionId span=spanMs(ms from first to last repeat) src=source st=state(d=denied,g=granted,p=prompt) sub_sdp_sum=subscriberSdp t=type(a=answer,o=offer) tr=tracks tt=trackType(TRACK_TYPE_UNSPECIFIED=0,audio=1,video=2) u=userId uid=userId usid=unifiedSessionId v=video vi=videoinput w=width wh=dimension([width,height])\nIceTrickle: c=iceCandidate tr=protocol\naddIceCandidate: tr=protocol\nonicecandidate: tr=protocol\nStates(int): conn(new=0,connecting=1,connected=2,disconnected=3,failed=4,closed=5) iceConn(new=0,checking=1,connected=2,completed=3,failed=4,disconnected=5,closed=6) iceGather(new=0,gathering=1,complete=2) signaling(stable=0,have-local-offer=1,have-remote-offer=2,have-local-pranswer=3,have-remote-pranswer=4,closed=5)"
+
+// SDPDigestFields maps abbreviated SDP digest (sdp_sum) keys to their meanings.
+const SDPDigestFields = "sdp_sum fields: type=offer|answer sdp_hash=sha256 bundle_mids=bundledMediaLineIds ice_lite=iceLite media=mediaLines mid=mediaLineId kind=audio|video|application dir=sendrecv|sendonly|recvonly|inactive rejected=portIsZero codecs=orderedCodecNames sim_rids=simulcastRIDCount tcc=transportWideCCEnabled"
@@ -0,0 +1,34 @@
+//go:build ignore
+
+// gen writes fields_gen.go, the field references built by package
+// reference. Run it with go generate after changing a handler table.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+
+	"rtcstats/internal/prompts/reference"
+)
+
+func main() {
+	var b bytes.Buffer
+	b.WriteString("// Code generated by go run gen.go; DO NOT EDIT.\n\npackage prompts\n")
+	for _, c := range []struct{ name, doc, value string }{
+		{"StatsFields", "maps abbreviated getstats report type and field keys to their meanings.", reference.StatsFields()},
+		{"EventFields", "maps abbreviated connection event payload keys to their meanings.", reference.EventFields()},
+		{"SDPDigestFields", "maps abbreviated SDP digest (sdp_sum) keys to their meanings.", reference.SDPDigestFields()},
+	} {
+		fmt.Fprintf(&b, "\n// %s %s\nconst %s = %q\n", c.name, c.doc, c.name, c.value)
+	}
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("fields_gen.go", src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
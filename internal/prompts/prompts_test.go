@@ -0,0 +1,26 @@
+package prompts
+
+import (
+	"testing"
+
+	"rtcstats/internal/prompts/reference"
+)
+
+func TestReferenceUpToDate(t *testing.T) {
+	if err := reference.Check(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		got, want string
+	}{
+		{"StatsFields", StatsFields, reference.StatsFields()},
+		{"EventFields", EventFields, reference.EventFields()},
+		{"SDPDigestFields", SDPDigestFields, reference.SDPDigestFields()},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s is stale, run go generate ./internal/prompts", tt.name)
+		}
+	}
+}
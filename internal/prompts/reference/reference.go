@@ -0,0 +1,350 @@
+// Package reference builds the field references of package prompts from
+// the tables the handlers compress with. gen.go in package prompts writes
+// them to fields_gen.go, so prompts holds them as constants.
+package reference
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"rtcstats/internal/handlers"
+	"rtcstats/internal/ice"
+	"rtcstats/internal/sdp"
+	"rtcstats/internal/transform"
+)
+
+// statsNotes adds units and ranges to getstats fields, by WebRTC field name.
+var statsNotes = map[string]string{
+	"jitter":                   "sec",
+	"audioLevel":               "0-1",
+	"totalSamplesDuration":     "sec",
+	"totalEncodeTime":          "sec",
+	"totalDecodeTime":          "sec",
+	"totalInterFrameDelay":     "sec",
+	"totalAssemblyTime":        "sec",
+	"totalProcessingDelay":     "sec",
+	"jitterBufferDelay":        "sec",
+	"jitterBufferMinimumDelay": "sec",
+	"jitterBufferTargetDelay":  "sec",
+	"totalFreezesDuration":     "sec",
+	"roundTripTime":            "sec",
+	"currentRoundTripTime":     "sec",
+	"totalRoundTripTime":       "sec",
+	"score":                    "0-100",
+	"mosScore":                 "1-5",
+}
+
+// sparseStats are the getstats counters that stay 0, and so absent, for
+// most of a healthy call.
+var sparseStats = map[string]bool{
+	"concealedSamples":              true,
+	"concealmentEvents":             true,
+	"removedSamplesForAcceleration": true,
+	"silentConcealedSamples":        true,
+	"packetsLost":                   true,
+	"packetsDiscarded":              true,
+	"nackCount":                     true,
+	"keyFramesDecoded":              true,
+	"pliCount":                      true,
+	"hugeFramesSent":                true,
+	"freezeCount":                   true,
+	"totalFreezesDuration":          true,
+	"framesDropped":                 true,
+}
+
+// eventNotes describes the layout of event payload values, by short key.
+var eventNotes = map[string]string{
+	"sdk":     "[type,version]",
+	"os":      "[name,version,arch]",
+	"br":      "[name,majorVersion]",
+	"wh":      "[width,height]",
+	"sc":      "[[rid,kbps,width,height]]",
+	"sdp_sum": "see sdp_sum fields",
+	"dur":     "ms",
+	"span":    "ms from first to last repeat",
+	"cand":    "[{t=type,tr=transport,n=count}]",
+	"r":       "fast_reconnect|migration|rejoin|reconnect",
+	"gap":     "ms since the previous connection went down",
+}
+
+// sdpFields describes the fields of an SDP digest, by JSON key.
+var sdpFields = map[string]string{
+	"type":        "offer|answer",
+	"sdp_hash":    "sha256",
+	"bundle_mids": "bundledMediaLineIds",
+	"ice_lite":    "iceLite",
+	"media":       "mediaLines",
+	"mid":         "mediaLineId",
+	"kind":        "audio|video|application",
+	"dir":         "sendrecv|sendonly|recvonly|inactive",
+	"rejected":    "portIsZero",
+	"codecs":      "orderedCodecNames",
+	"sim_rids":    "simulcastRIDCount",
+	"tcc":         "transportWideCCEnabled",
+}
+
+// stateLabels names the state change events in the reference.
+var stateLabels = map[string]string{
+	"signalingstatechange":     "signaling",
+	"iceconnectionstatechange": "iceConn",
+	"icegatheringstatechange":  "iceGather",
+	"connectionstatechange":    "conn",
+}
+
+// StatsFields lists the getstats categories and, once per meaning,
+// their fields. A short key that means something else in a later category
+// is listed again as category.key.
+func StatsFields() string {
+	var b strings.Builder
+	b.WriteString("Δ=delta(change since last sample, omitted when 0) G=gauge(snapshot, omitted when 0/null) S=sparse(only present when non-zero)\nReport types:")
+	for _, c := range handlers.Categories {
+		fmt.Fprintf(&b, " %s=%s", c.Key, c.Name)
+	}
+	b.WriteString("\nFields:")
+	meaning := make(map[string]string)
+	for _, c := range handlers.Categories {
+		for _, f := range handlers.CategoryFields(c.Key) {
+			key := f.Short
+			if prev, ok := meaning[key]; ok {
+				if prev == f.Original {
+					continue
+				}
+				key = c.Key + "." + f.Short
+			} else {
+				meaning[key] = f.Original
+			}
+			fmt.Fprintf(&b, " %s=%s(%s)", key, f.Original, statsNote(f))
+		}
+	}
+	return b.String()
+}
+
+func statsNote(f handlers.Field) string {
+	note := "G"
+	if f.IsCounter {
+		note = "Δ"
+	}
+	if sparseStats[f.Original] {
+		note += "S"
+	}
+	if unit, ok := statsNotes[f.Original]; ok {
+		note += "," + unit
+	}
+	return note
+}
+
+// eventKeys returns the description of every short event payload key.
+func eventKeys() map[string]string {
+	keys := make(map[string]string, len(transform.FieldNames)+len(handlers.PayloadKeys))
+	for short, name := range transform.FieldNames {
+		keys[short] = name
+	}
+	for short, name := range handlers.PayloadKeys {
+		keys[short] = name
+	}
+	return keys
+}
+
+// EventFields lists the event payload keys with their value codes,
+// the event-specific keys and the state change codes.
+func EventFields() string {
+	var b strings.Builder
+	b.WriteString("Fields:")
+	keys := eventKeys()
+	for _, short := range sortedKeys(keys) {
+		fmt.Fprintf(&b, " %s=%s%s", short, keys[short], keyNote(short))
+	}
+
+	for _, name := range sortedKeys(handlers.EventPayloadKeys) {
+		fmt.Fprintf(&b, "\n%s:", name)
+		overrides := handlers.EventPayloadKeys[name]
+		for _, short := range sortedKeys(overrides) {
+			fmt.Fprintf(&b, " %s=%s", short, overrides[short])
+		}
+	}
+
+	b.WriteString("\nStates(int):")
+	for _, name := range sortedKeys(transform.StateEvents) {
+		fmt.Fprintf(&b, " %s%s", stateLabels[name], stateCodes(transform.StateEvents[name]))
+	}
+	return b.String()
+}
+
+// keyNote annotates a payload key with its value codes or layout.
+func keyNote(short string) string {
+	switch {
+	case handlers.FlagKeys[short]:
+		return "(1/0)"
+	case transform.KeyCodes[short] != nil:
+		codes := transform.KeyCodes[short]
+		list := make([]string, 0, len(codes))
+		for _, code := range sortedValues(codes) {
+			list = append(list, code+"="+transform.CodeName(codes, code))
+		}
+		return "(" + strings.Join(list, ",") + ")"
+	case transform.KeyStates[short] != nil:
+		return stateCodes(transform.KeyStates[short])
+	case eventNotes[short] != "":
+		return "(" + eventNotes[short] + ")"
+	}
+	return ""
+}
+
+// stateCodes lists the codes of an integer enum in order, as (name=code,...).
+func stateCodes(states map[string]int) string {
+	seen := make(map[int]bool)
+	var codes []int
+	for _, code := range states {
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	sort.Ints(codes)
+	list := make([]string, len(codes))
+	for i, code := range codes {
+		list[i] = transform.StateName(states, code) + "=" + strconv.Itoa(code)
+	}
+	return "(" + strings.Join(list, ",") + ")"
+}
+
+// SDPDigestFields lists the fields of sdp.Digest and its media
+// entries in declaration order.
+func SDPDigestFields() string {
+	var b strings.Builder
+	b.WriteString("sdp_sum fields:")
+	for _, key := range jsonKeys(sdp.Digest{}, sdp.MediaEntry{}) {
+		fmt.Fprintf(&b, " %s=%s", key, sdpFields[key])
+	}
+	return b.String()
+}
+
+// jsonKeys returns the JSON keys of the fields of the given structs.
+func jsonKeys(structs ...interface{}) []string {
+	var keys []string
+	for _, s := range structs {
+		t := reflect.TypeOf(s)
+		for i := 0; i < t.NumField(); i++ {
+			tag := t.Field(i).Tag.Get("json")
+			if name, _, _ := strings.Cut(tag, ","); name != "" && name != "-" {
+				keys = append(keys, name)
+			}
+		}
+	}
+	return keys
+}
+
+// Check verifies that every short key the handlers can write has a
+// description, and that the notes above only refer to existing keys.
+func Check() error {
+	var problems []string
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	fields := make(map[string]bool)
+	for _, c := range handlers.Categories {
+		if c.Name == "" {
+			fail("getstats category %s has no description", c.Key)
+		}
+		list := handlers.CategoryFields(c.Key)
+		if len(list) == 0 {
+			fail("getstats category %s has no fields", c.Key)
+		}
+		for _, f := range list {
+			if f.Original == "" {
+				fail("getstats field %s.%s has no description", c.Key, f.Short)
+			}
+			fields[f.Original] = true
+		}
+	}
+	for _, name := range sortedKeys(statsNotes) {
+		if !fields[name] {
+			fail("note for unknown getstats field %s", name)
+		}
+	}
+	for _, name := range sortedKeys(sparseStats) {
+		if !fields[name] {
+			fail("unknown sparse getstats field %s", name)
+		}
+	}
+
+	keys := eventKeys()
+	for _, short := range sortedKeys(keys) {
+		if keys[short] == "" {
+			fail("payload key %s has no description", short)
+		}
+	}
+	for name, overrides := range handlers.EventPayloadKeys {
+		for short, desc := range overrides {
+			if desc == "" {
+				fail("payload key %s of %s has no description", short, name)
+			}
+		}
+	}
+	// Keys that carry codes or notes, plus those written by the ICE
+	// candidate summary and the device counts, must all be described
+	var written []string
+	written = append(written, jsonKeys(ice.CandidateSummary{})...)
+	written = append(written, sortedValues(transform.DeviceKind)...)
+	written = append(written, sortedKeys(handlers.FlagKeys)...)
+	written = append(written, sortedKeys(transform.KeyCodes)...)
+	written = append(written, sortedKeys(transform.KeyStates)...)
+	written = append(written, sortedKeys(eventNotes)...)
+	for _, short := range written {
+		if keys[short] == "" {
+			fail("payload key %s has no description", short)
+		}
+	}
+
+	for _, name := range sortedKeys(transform.StateEvents) {
+		if stateLabels[name] == "" {
+			fail("state event %s has no description", name)
+		}
+	}
+
+	digestKeys := make(map[string]bool)
+	for _, key := range jsonKeys(sdp.Digest{}, sdp.MediaEntry{}) {
+		digestKeys[key] = true
+		if sdpFields[key] == "" {
+			fail("sdp_sum field %s has no description", key)
+		}
+	}
+	for _, key := range sortedKeys(sdpFields) {
+		if !digestKeys[key] {
+			fail("description for unknown sdp_sum field %s", key)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("prompts: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of a map with string keys, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedValues returns the distinct values of a string map, sorted.
+func sortedValues(m map[string]string) []string {
+	seen := make(map[string]bool, len(m))
+	values := make([]string, 0, len(m))
+	for _, v := range m {
+		if !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
@@ -40,8 +40,12 @@ func (d *InterestDetector) IsInteresting(scope string, payload interface{}) bool
 	}
 
 	// Check trigger fields within each report category
+	// Every category is checked, even once one is interesting, so that the
+	// gauges remembered for the next sample do not depend on map order
 	for catKey, catVal := range result {
-		interesting = interesting || d.checkCategory(scope, catKey, catVal)
+		if d.checkCategory(scope, catKey, catVal) {
+			interesting = true
+		}
 	}
 
 	// Update previous state
@@ -147,3 +151,9 @@ func toFloat(v interface{}) (float64, bool) {
 		return 0, false
 	}
 }
+
+// ResetScope forgets what the detector saw in scope.
+func (d *InterestDetector) ResetScope(scope string) {
+	delete(d.prevCategories, scope)
+	delete(d.prevGauges, scope)
+}
@@ -1,6 +1,8 @@
 package sampling
 
 import (
+	"sort"
+
 	"rtcstats/internal/event"
 	"rtcstats/internal/handlers"
 )
@@ -99,8 +101,17 @@ func (s *Sampler) ProcessGetStats(ce event.CompressedEvent, payload interface{},
 }
 
 // Flush drains all buffers, force-keeping the last sample per scope.
+// Samples are emitted in time order across scopes, ties in scope order.
 func (s *Sampler) Flush() {
-	for _, st := range s.scopes {
+	names := make([]string, 0, len(s.scopes))
+	for name := range s.scopes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var kept []bufferedSample
+	for _, name := range names {
+		st := s.scopes[name]
 		if len(st.buffer) == 0 {
 			continue
 		}
@@ -109,9 +120,49 @@ func (s *Sampler) Flush() {
 
 		for _, sample := range st.buffer {
 			if sample.keep {
-				s.emitFunc(sample.event, sample.snapshot)
+				kept = append(kept, sample)
 			}
 		}
 		st.buffer = nil
 	}
+
+	sort.SliceStable(kept, func(i, j int) bool {
+		return sampleTime(kept[i].event) < sampleTime(kept[j].event)
+	})
+	for _, sample := range kept {
+		s.emitFunc(sample.event, sample.snapshot)
+	}
+}
+
+// FlushScope drains the buffer of one scope like Flush, then starts the
+// scope over: its next sample is kept like a first one.
+func (s *Sampler) FlushScope(scope string) {
+	st := s.scopes[scope]
+	if st == nil {
+		return
+	}
+	if n := len(st.buffer); n > 0 {
+		st.buffer[n-1].keep = true
+	}
+	for _, sample := range st.buffer {
+		if sample.keep {
+			s.emitFunc(sample.event, sample.snapshot)
+		}
+	}
+	delete(s.scopes, scope)
+}
+
+// ResetScope flushes scope like FlushScope and forgets what the interest
+// detector saw in it, for when the scope's peer connection is replaced.
+func (s *Sampler) ResetScope(scope string) {
+	s.FlushScope(scope)
+	s.detector.ResetScope(scope)
+}
+
+// sampleTime returns the ts of ce, or its dt in delta timestamp mode.
+func sampleTime(ce event.CompressedEvent) int64 {
+	if ce.TS == 0 && ce.DT != nil {
+		return *ce.DT
+	}
+	return ce.TS
 }
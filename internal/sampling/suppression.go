@@ -54,3 +54,14 @@ func (s *SteadyStateSuppressor) Suppress(scope string, payload interface{}) inte
 
 	return suppressed
 }
+
+// Reset forgets the last emitted payloads, so the next payload of every
+// scope is emitted in full.
+func (s *SteadyStateSuppressor) Reset() {
+	s.lastEmitted = make(map[string]interface{})
+}
+
+// ResetScope forgets the last emitted payload of one scope.
+func (s *SteadyStateSuppressor) ResetScope(scope string) {
+	delete(s.lastEmitted, scope)
+}
@@ -0,0 +1,132 @@
+// Package statsdelta rebuilds full getstats reports from payloads that were
+// delta-compressed by the rtcstats client library, which only sends the
+// fields that changed since the previous report.
+package statsdelta
+
+import (
+	"encoding/json"
+
+	"rtcstats/internal/event"
+)
+
+// Mode selects how getstats payloads are interpreted.
+type Mode int
+
+const (
+	ModeAuto Mode = iota // decode once a delta-compressed payload is seen
+	ModeOff              // payloads are always full reports
+	ModeOn               // payloads are always delta-compressed
+)
+
+// Decoder holds the last full report per scope and entry so that delta
+// payloads can be expanded before classification.
+type Decoder struct {
+	mode   Mode
+	active bool
+	base   map[string]map[string]map[string]interface{} // scope → entryID → field → value
+}
+
+// NewDecoder creates a Decoder for the given mode.
+func NewDecoder(mode Mode) *Decoder {
+	return &Decoder{
+		mode:   mode,
+		active: mode == ModeOn,
+		base:   make(map[string]map[string]map[string]interface{}),
+	}
+}
+
+// Decode returns e with its payload expanded to a full stats report.
+// Events are returned unchanged when decoding is off or not yet detected.
+func (d *Decoder) Decode(e event.RawEvent) event.RawEvent {
+	if d.mode == ModeOff {
+		return e
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(e.Payload, &payload); err != nil {
+		return e
+	}
+
+	if !d.active {
+		if !isDeltaCompressed(payload) {
+			return e
+		}
+		d.active = true
+	}
+
+	scope := ""
+	if e.Scope != nil {
+		scope = *e.Scope
+	}
+	base := d.base[scope]
+	if base == nil {
+		base = make(map[string]map[string]interface{})
+		d.base[scope] = base
+	}
+
+	// The client moves the newest report timestamp to the top level and
+	// zeroes it in every entry that shares it.
+	reportTS, hasReportTS := payload["timestamp"].(float64)
+
+	for entryID, raw := range payload {
+		entry, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		prev := base[entryID]
+		full := make(map[string]interface{}, len(prev)+len(entry))
+		for k, v := range prev {
+			full[k] = v
+		}
+		for k, v := range entry {
+			full[k] = v
+		}
+
+		if ts, ok := entry["timestamp"].(float64); ok && ts == 0 && hasReportTS {
+			full["timestamp"] = reportTS
+		}
+
+		base[entryID] = full
+		payload[entryID] = full
+	}
+
+	// The client leaves unchanged entries out, so they carry over from the
+	// previous report. Entries are only forgotten by ResetScope.
+	for entryID, prev := range base {
+		if _, ok := payload[entryID]; !ok {
+			payload[entryID] = prev
+		}
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return e
+	}
+	e.Payload = data
+	return e
+}
+
+// ResetScope forgets the last full report of scope. Use it when the
+// scope's peer connection is replaced, as the new one starts from a full
+// report.
+func (d *Decoder) ResetScope(scope string) {
+	delete(d.base, scope)
+}
+
+// isDeltaCompressed reports whether a payload carries the markers the
+// rtcstats client adds when delta-compressing: a numeric top-level
+// "timestamp", or entries whose timestamp was zeroed.
+func isDeltaCompressed(payload map[string]interface{}) bool {
+	if _, ok := payload["timestamp"].(float64); ok {
+		return true
+	}
+	for _, raw := range payload {
+		if entry, ok := raw.(map[string]interface{}); ok {
+			if ts, ok := entry["timestamp"].(float64); ok && ts == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package tokens
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"rtcstats/internal/event"
+)
+
+// maxPending bounds how much text Counter holds while waiting for a line
+// break, so inputs without newlines (webrtc-internals dumps) stay bounded.
+// Past it, text is counted up to the last space or comma, or all of it if
+// there is none.
+const maxPending = 64 * 1024
+
+// Counter is an io.Writer that counts the tokens of everything written to
+// it. Text is counted a line at a time so tokens are not split across
+// Write calls.
+type Counter struct {
+	t       Tokenizer
+	pending []byte
+	total   int
+}
+
+// NewCounter creates a Counter using t.
+func NewCounter(t Tokenizer) *Counter {
+	return &Counter{t: t}
+}
+
+func (c *Counter) Write(p []byte) (int, error) {
+	c.pending = append(c.pending, p...)
+	cut := bytes.LastIndexByte(c.pending, '\n')
+	if cut < 0 && len(c.pending) > maxPending {
+		cut = bytes.LastIndexAny(c.pending, " ,")
+		if cut < 0 {
+			// Nowhere to break (e.g. a long base64 blob); a token may be
+			// split here, which costs at most one token of accuracy
+			cut = len(c.pending) - 1
+		}
+	}
+	if cut >= 0 {
+		c.total += c.t.Count(c.pending[:cut+1])
+		c.pending = append(c.pending[:0], c.pending[cut+1:]...)
+	}
+	return len(p), nil
+}
+
+// Total returns the token count of everything written so far.
+func (c *Counter) Total() int {
+	return c.total + c.t.Count(c.pending)
+}
+
+// Encoder wraps an event.Encoder and attributes the tokens of every record
+// it writes to the record's event name and, for getstats, to each report
+// category. Tabular getstats payloads map categories to value rows, and
+// "=" categories to the marker, so they are attributed the same way; the
+// getstats.cols header is counted as an event of its own.
+type Encoder struct {
+	enc        event.Encoder
+	buf        bytes.Buffer
+	w          io.Writer
+	t          Tokenizer
+	total      int
+	byEvent    map[string]int
+	byCategory map[string]int
+}
+
+// NewEncoder creates an Encoder writing to w through the encoder built by newEnc.
+func NewEncoder(w io.Writer, newEnc func(io.Writer) event.Encoder, t Tokenizer) *Encoder {
+	e := &Encoder{
+		w:          w,
+		t:          t,
+		byEvent:    make(map[string]int),
+		byCategory: make(map[string]int),
+	}
+	e.enc = newEnc(&e.buf)
+	return e
+}
+
+// Encode encodes ce, counts its tokens and writes it to the underlying writer.
+func (e *Encoder) Encode(ce event.CompressedEvent) error {
+	e.buf.Reset()
+	if err := e.enc.Encode(ce); err != nil {
+		return err
+	}
+
+	n := e.t.Count(e.buf.Bytes())
+	e.total += n
+	e.byEvent[ce.Name] += n
+
+	if ce.Name == "getstats" {
+		if payload, ok := ce.Payload.(map[string]interface{}); ok {
+			for cat, val := range payload {
+				data, err := json.Marshal(map[string]interface{}{cat: val})
+				if err != nil {
+					continue
+				}
+				// Drop the enclosing braces
+				e.byCategory[cat] += e.t.Count(data[1 : len(data)-1])
+			}
+		}
+	}
+
+	_, err := e.w.Write(e.buf.Bytes())
+	return err
+}
+
+// Total returns the token count of all records written.
+func (e *Encoder) Total() int {
+	return e.total
+}
+
+// ByEvent returns output tokens per event name.
+func (e *Encoder) ByEvent() map[string]int {
+	return e.byEvent
+}
+
+// ByCategory returns output tokens per getstats category (out_v, in_a, ...).
+func (e *Encoder) ByCategory() map[string]int {
+	return e.byCategory
+}
@@ -0,0 +1,75 @@
+package tokens
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"rtcstats/internal/event"
+	"rtcstats/internal/handlers"
+)
+
+func TestEncoderByCategory(t *testing.T) {
+	stats := map[string]interface{}{
+		"in_a": map[string]interface{}{"br": 1200.0, "pr": 10.0},
+		"out_v": []map[string]interface{}{
+			{"bs": 5000.0, "fps": 30.0},
+			{},
+		},
+	}
+	_, rows := handlers.NewTabulator().Tabulate("0-pub", stats)
+
+	tests := []struct {
+		name    string
+		payload interface{}
+		want    []string
+	}{
+		{"fields", stats, []string{"in_a", "out_v"}},
+		{"tabular", rows, []string{"in_a", "out_v"}},
+		{"unchanged", map[string]interface{}{"in_a": "=", "cq": map[string]interface{}{"s": 90.0}}, []string{"in_a", "cq"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := NewEncoder(io.Discard, func(w io.Writer) event.Encoder {
+				return event.NewEncoder(w, event.FormatJSONL)
+			}, Approx{})
+			if err := enc.Encode(event.CompressedEvent{Name: "getstats", Scope: "0-pub", Payload: tt.payload}); err != nil {
+				t.Fatal(err)
+			}
+			byCategory := enc.ByCategory()
+			if len(byCategory) != len(tt.want) {
+				t.Errorf("got categories %v, want %v", byCategory, tt.want)
+			}
+			for _, cat := range tt.want {
+				if byCategory[cat] == 0 {
+					t.Errorf("%s has no tokens: %v", cat, byCategory)
+				}
+			}
+		})
+	}
+}
+
+func TestCounterBoundsPending(t *testing.T) {
+	tests := []struct {
+		name  string
+		chunk []byte
+	}{
+		{"no breaks", bytes.Repeat([]byte("a"), 4096)},
+		{"spaces", bytes.Repeat([]byte("abc "), 1024)},
+		{"lines", bytes.Repeat([]byte("abc\n"), 1024)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCounter(Approx{})
+			for i := 0; i < 64; i++ {
+				c.Write(tt.chunk)
+				if len(c.pending) > maxPending+len(tt.chunk) {
+					t.Fatalf("pending grew to %d bytes", len(c.pending))
+				}
+			}
+			if c.Total() == 0 {
+				t.Error("counted no tokens")
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+// Package tokens estimates LLM token counts for input and output text.
+package tokens
+
+// Tokenizer counts the tokens in a piece of text.
+type Tokenizer interface {
+	Count(text []byte) int
+}
+
+// Approx is an offline approximation of a byte-pair-encoding tokenizer
+// (cl100k-style). It splits text the way BPE pre-tokenizers do — letter
+// runs (further split at camelCase boundaries), digit runs, whitespace and
+// punctuation — and charges each piece the number of tokens BPE typically
+// needs for it. Estimates are within a few percent for JSON and tend to
+// err on the high side.
+type Approx struct{}
+
+// byte classes used by Approx
+const (
+	classOther = iota
+	classLetter
+	classDigit
+	classSpace
+)
+
+func classify(b byte) int {
+	switch {
+	case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b == '_', b >= 0x80:
+		return classLetter
+	case b >= '0' && b <= '9':
+		return classDigit
+	case b == ' ' || b == '\t' || b == '\n' || b == '\r':
+		return classSpace
+	default:
+		return classOther
+	}
+}
+
+// Count returns the estimated token count of text.
+func (Approx) Count(text []byte) int {
+	n := 0
+	for i := 0; i < len(text); {
+		class := classify(text[i])
+		j := i + 1
+		for j < len(text) && classify(text[j]) == class {
+			j++
+		}
+		run := text[i:j]
+
+		switch class {
+		case classLetter:
+			n += letterTokens(run)
+		case classDigit:
+			// Numbers are split into groups of up to three digits
+			n += (len(run) + 2) / 3
+		case classSpace:
+			// A single space before a word is merged into the word's token
+			if !(len(run) == 1 && run[0] == ' ' && j < len(text) && classify(text[j]) == classLetter) {
+				n++
+			}
+		default:
+			// Common JSON punctuation pairs ({" ": ",) are single tokens
+			n += (len(run) + 1) / 2
+		}
+		i = j
+	}
+	return n
+}
+
+// letterTokens charges one token per camelCase word, plus one per six
+// further characters for long words.
+func letterTokens(run []byte) int {
+	n := 0
+	start := 0
+	for i := 1; i <= len(run); i++ {
+		if i == len(run) || (isUpper(run[i]) && !isUpper(run[i-1])) {
+			n += (i - start + 5) / 6
+			start = i
+		}
+	}
+	return n
+}
+
+func isUpper(b byte) bool {
+	return b >= 'A' && b <= 'Z'
+}
@@ -0,0 +1,59 @@
+package transform
+
+import "strings"
+
+// FieldNames inverts FieldMap. Where two originals share a short key
+// (userId, user_id), the camelCase one is used.
+var FieldNames = func() map[string]string {
+	m := make(map[string]string, len(FieldMap))
+	for orig, short := range FieldMap {
+		if prev, ok := m[short]; !ok || strings.Contains(prev, "_") {
+			m[short] = orig
+		}
+	}
+	return m
+}()
+
+// SDPType maps session description types to short codes
+var SDPType = map[string]string{
+	"offer":  "o",
+	"answer": "a",
+}
+
+// Direction maps track directions to short codes
+var Direction = map[string]string{
+	"inbound":  "in",
+	"outbound": "out",
+}
+
+// PeerType maps SFU peer types to integers
+var PeerType = map[string]int{
+	"publisher":  0,
+	"subscriber": 1,
+}
+
+// StateEvents maps the state change events, whose payload is a bare
+// integer code, to the table of their codes.
+var StateEvents = map[string]map[string]int{
+	"signalingstatechange":     SignalingState,
+	"iceconnectionstatechange": ICEConnectionState,
+	"icegatheringstatechange":  ICEGatheringState,
+	"connectionstatechange":    ConnectionState,
+}
+
+// KeyCodes maps payload keys whose values are short string codes to the
+// table of their codes.
+var KeyCodes = map[string]map[string]string{
+	"k":   MediaKind,
+	"st":  PermissionState,
+	"bp":  BundlePolicy,
+	"t":   SDPType,
+	"dir": Direction,
+}
+
+// KeyStates maps payload keys whose values are integer codes to the table
+// of their codes.
+var KeyStates = map[string]map[string]int{
+	"tt": TrackType,
+	"pt": PeerType,
+}
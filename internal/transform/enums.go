@@ -59,6 +59,13 @@ var PermissionState = map[string]string{
 	"denied":  "d",
 }
 
+// BundlePolicy maps bundle policies to short codes
+var BundlePolicy = map[string]string{
+	"max-bundle": "mb",
+	"max-compat": "mc",
+	"balanced":   "b",
+}
+
 // TrackType maps track type strings/numbers to integers
 var TrackType = map[string]int{
 	"TRACK_TYPE_UNSPECIFIED": 0,
@@ -132,6 +139,14 @@ func CompressPermissionState(state string) string {
 	return state
 }
 
+// CompressBundlePolicy returns short code for bundle policy
+func CompressBundlePolicy(policy string) string {
+	if short, ok := BundlePolicy[policy]; ok {
+		return short
+	}
+	return policy
+}
+
 // CompressTrackType returns int for track type
 func CompressTrackType(tt interface{}) int {
 	switch v := tt.(type) {
@@ -146,3 +161,31 @@ func CompressTrackType(tt interface{}) int {
 	}
 	return 0
 }
+
+// StateName returns the name that m maps to code, preferring the shortest
+// (then alphabetically first) when several names share it. It returns ""
+// when no name matches.
+func StateName(m map[string]int, code int) string {
+	name := ""
+	for k, v := range m {
+		if v == code && (name == "" || len(k) < len(name) || (len(k) == len(name) && k < name)) {
+			name = k
+		}
+	}
+	return name
+}
+
+// CodeName is StateName for maps to short string codes. It returns code
+// unchanged when no name matches.
+func CodeName(m map[string]string, code string) string {
+	name := ""
+	for k, v := range m {
+		if v == code && (name == "" || len(k) < len(name) || (len(k) == len(name) && k < name)) {
+			name = k
+		}
+	}
+	if name == "" {
+		return code
+	}
+	return name
+}
@@ -0,0 +1,170 @@
+package transform
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IDFields maps the payload fields holding user, session, device and group
+// IDs to the prefix of their aliases.
+var IDFields = map[string]string{
+	"userId":           "u",
+	"user_id":          "u",
+	"sessionId":        "s",
+	"session_id":       "s",
+	"unifiedSessionId": "s",
+	"deviceId":         "d",
+	"groupId":          "g",
+}
+
+// idFieldNames holds the IDFields keys as quoted JSON, to find payloads
+// that need rewriting without decoding them.
+var idFieldNames = func() [][]byte {
+	names := make([][]byte, 0, len(IDFields))
+	for name := range IDFields {
+		names = append(names, []byte(strconv.Quote(name)))
+	}
+	return names
+}()
+
+// Pseudonymizer replaces identifiers with short aliases. Without a salt,
+// aliases are numbered in order of first appearance (u1, u2, s1, ...).
+// With a salt, they are derived from a keyed hash of the ID
+// (u3f9a02c1d47e8b60), so the same salt gives the same alias in every file.
+// The 64-bit hash prefix makes two IDs sharing an alias practically
+// impossible, so an alias never depends on which IDs an output holds.
+type Pseudonymizer struct {
+	salt    []byte            // nil for numbered aliases
+	aliases map[string]string // by prefix and ID
+	ids     map[string]string // by alias
+	counts  map[string]int    // numbered aliases issued, by prefix
+}
+
+// NewPseudonymizer returns a Pseudonymizer keyed by salt, or numbering
+// its aliases if salt is "".
+func NewPseudonymizer(salt string) *Pseudonymizer {
+	p := &Pseudonymizer{
+		aliases: make(map[string]string),
+		ids:     make(map[string]string),
+		counts:  make(map[string]int),
+	}
+	if salt != "" {
+		p.salt = []byte(salt)
+	}
+	return p
+}
+
+// Alias returns the alias of id, with prefix "u", "s", "d" or "g" (see
+// IDFields). "" stays "".
+func (p *Pseudonymizer) Alias(prefix, id string) string {
+	if id == "" {
+		return ""
+	}
+	key := prefix + ":" + id
+	if alias, ok := p.aliases[key]; ok {
+		return alias
+	}
+
+	var alias string
+	if p.salt == nil {
+		p.counts[prefix]++
+		alias = prefix + strconv.Itoa(p.counts[prefix])
+	} else {
+		mac := hmac.New(sha256.New, p.salt)
+		mac.Write([]byte(key))
+		alias = prefix + hex.EncodeToString(mac.Sum(nil))[:16]
+	}
+	p.aliases[key] = alias
+	p.ids[alias] = id
+	return alias
+}
+
+// IsSaltedAlias reports whether s has the form of a salted alias: an
+// IDFields prefix followed by 16 lowercase hex digits. Numbered aliases
+// need no check, as they are short.
+func IsSaltedAlias(s string) bool {
+	if len(s) != 17 || !strings.ContainsRune("usdg", rune(s[0])) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if c := s[i]; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Payload replaces the IDs in a JSON payload: every string in the value
+// of an IDFields field, at any depth. A payload without such a field is
+// returned as it is.
+func (p *Pseudonymizer) Payload(data json.RawMessage) json.RawMessage {
+	if !hasIDField(data) {
+		return data
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return data
+	}
+	out, err := json.Marshal(p.replace(v, ""))
+	if err != nil {
+		return data
+	}
+	return out
+}
+
+// replace replaces the IDs in v, which is held by a field with alias
+// prefix, or "" if it is not an ID field.
+func (p *Pseudonymizer) replace(v interface{}, prefix string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		// In key order, so numbered aliases do not depend on map order
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			elem := v[k]
+			elemPrefix := prefix
+			if idPrefix, ok := IDFields[k]; ok {
+				elemPrefix = idPrefix
+			}
+			v[k] = p.replace(elem, elemPrefix)
+		}
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = p.replace(elem, prefix)
+		}
+	case string:
+		if prefix != "" {
+			return p.Alias(prefix, v)
+		}
+	}
+	return v
+}
+
+// Table returns the IDs by alias.
+func (p *Pseudonymizer) Table() map[string]string {
+	table := make(map[string]string, len(p.ids))
+	for alias, id := range p.ids {
+		table[alias] = id
+	}
+	return table
+}
+
+func hasIDField(data []byte) bool {
+	for _, name := range idFieldNames {
+		if bytes.Contains(data, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -11,12 +11,17 @@ var sfuHostnamePattern = regexp.MustCompile(`^(\d+)-sfu-[a-z]+-([a-z]+-[a-z0-9]+
 // CompressScope compresses scope strings
 // Short scopes like "0-pub", "0-sub" are kept as-is
 // SFU hostnames are compressed to "sfu:<region>"
+// A participant alias prefix from merged dumps ("p1/...") is preserved
 func CompressScope(scope *string) string {
 	if scope == nil {
 		return ""
 	}
 
 	s := *scope
+	if idx := strings.IndexByte(s, '/'); idx > 0 {
+		rest := s[idx+1:]
+		return s[:idx+1] + CompressScope(&rest)
+	}
 
 	// Keep short scopes as-is
 	if len(s) <= 10 || strings.HasSuffix(s, "-pub") || strings.HasSuffix(s, "-sub") {
@@ -83,3 +88,26 @@ func isCluster(s string) bool {
 	}
 	return false
 }
+
+// DescribeScope explains a compressed scope in words, e.g. "0-pub" →
+// "publisher peer connection 0". It returns "" for scopes it does not
+// recognize.
+func DescribeScope(scope string) string {
+	if idx := strings.IndexByte(scope, '/'); idx > 0 {
+		if rest := DescribeScope(scope[idx+1:]); rest != "" {
+			return "participant " + scope[:idx] + ": " + rest
+		}
+		return ""
+	}
+
+	switch {
+	case strings.HasPrefix(scope, "sfu:"):
+		return "SFU " + strings.TrimPrefix(scope, "sfu:")
+	case strings.HasSuffix(scope, "-pub"):
+		return "publisher peer connection " + strings.TrimSuffix(scope, "-pub")
+	case strings.HasSuffix(scope, "-sub"):
+		return "subscriber peer connection " + strings.TrimSuffix(scope, "-sub")
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,415 @@
+// Package webrtcinternals converts chrome://webrtc-internals JSON dumps
+// (webrtc_internals_dump.txt) into rtcstats RawEvents, so the regular
+// handlers can process them unchanged.
+package webrtcinternals
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"rtcstats/internal/event"
+)
+
+// dump mirrors the top-level structure of a webrtc-internals export.
+type dump struct {
+	PeerConnections map[string]peerConnection `json:"PeerConnections"`
+}
+
+type peerConnection struct {
+	RTCConfiguration string                 `json:"rtcConfiguration"`
+	UpdateLog        []updateLogEntry       `json:"updateLog"`
+	Stats            map[string]statsSeries `json:"stats"`
+}
+
+type updateLogEntry struct {
+	Time  json.RawMessage `json:"time"` // locale string, ISO string, or epoch ms
+	Type  string          `json:"type"`
+	Value string          `json:"value"`
+}
+
+// statsSeries is one "<entryID>-<field>" time series. Values is usually a
+// JSON-encoded array inside a string.
+type statsSeries struct {
+	StartTime json.RawMessage `json:"startTime"`
+	EndTime   json.RawMessage `json:"endTime"`
+	Values    json.RawMessage `json:"values"`
+}
+
+// eventNames maps webrtc-internals updateLog types to rtcstats event names.
+// Types not listed here are passed through verbatim.
+var eventNames = map[string]string{
+	"icecandidate":          "onicecandidate",
+	"onIceCandidate":        "onicecandidate",
+	"iceCandidate":          "onicecandidate",
+	"addIceCandidateFailed": "addIceCandidateOnFailure",
+	"track":                 "ontrack",
+	"onAddTrack":            "ontrack",
+	"onRenegotiationNeeded": "negotiationneeded",
+}
+
+// Reader yields the events of a converted dump in timestamp order.
+type Reader struct {
+	events []event.RawEvent
+	pos    int
+}
+
+// NewReader decodes a webrtc-internals dump from r. The dump is a single
+// JSON object, so it is decoded in full before events are produced.
+// Each PeerConnection id becomes the scope of its events.
+func NewReader(r io.Reader) (*Reader, error) {
+	var d dump
+	if err := json.NewDecoder(r).Decode(&d); err != nil {
+		return nil, fmt.Errorf("decoding webrtc-internals dump: %w", err)
+	}
+	if d.PeerConnections == nil {
+		return nil, fmt.Errorf("decoding webrtc-internals dump: no PeerConnections")
+	}
+
+	ids := make([]string, 0, len(d.PeerConnections))
+	for id := range d.PeerConnections {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var events []event.RawEvent
+	for _, id := range ids {
+		events = append(events, convertPeerConnection(id, d.PeerConnections[id])...)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].TS < events[j].TS
+	})
+
+	return &Reader{events: events}, nil
+}
+
+// Next returns the next event, or io.EOF when all events have been returned.
+func (r *Reader) Next() (event.RawEvent, error) {
+	if r.pos >= len(r.events) {
+		return event.RawEvent{}, io.EOF
+	}
+	e := r.events[r.pos]
+	r.pos++
+	return e, nil
+}
+
+// Count returns the number of events returned so far.
+func (r *Reader) Count() int {
+	return r.pos
+}
+
+func convertPeerConnection(id string, pc peerConnection) []event.RawEvent {
+	scope := id
+	var events []event.RawEvent
+
+	for _, entry := range pc.UpdateLog {
+		ts, ok := parseTime(entry.Time)
+		if !ok {
+			continue
+		}
+		name := entry.Type
+		if mapped, ok := eventNames[name]; ok {
+			name = mapped
+		}
+		events = append(events, event.RawEvent{
+			Name:    name,
+			Scope:   &scope,
+			Payload: convertValue(name, entry.Value),
+			TS:      ts,
+		})
+	}
+
+	events = append(events, convertStats(scope, pc.Stats)...)
+	if len(events) == 0 {
+		return nil
+	}
+
+	// The configuration is not part of the update log; synthesize a
+	// "create" event at the PeerConnection's first timestamp.
+	if create := convertConfiguration(pc.RTCConfiguration); create != nil {
+		first := events[0].TS
+		for _, e := range events {
+			if e.TS < first {
+				first = e.TS
+			}
+		}
+		events = append([]event.RawEvent{{
+			Name:    "create",
+			Scope:   &scope,
+			Payload: create,
+			TS:      first,
+		}}, events...)
+	}
+
+	return events
+}
+
+var (
+	bundlePolicyPattern = regexp.MustCompile(`bundlePolicy:\s*"?([a-z-]+)`)
+	iceURLPattern       = regexp.MustCompile(`(?:stuns?|turns?):[^\s,\]"]+`)
+)
+
+// convertConfiguration turns the non-JSON rtcConfiguration string
+// ("{ iceServers: [turn:...], bundlePolicy: max-bundle, ... }") into the
+// payload shape CreatePCHandler expects.
+func convertConfiguration(cfg string) json.RawMessage {
+	if cfg == "" {
+		return nil
+	}
+
+	payload := make(map[string]interface{})
+	if m := bundlePolicyPattern.FindStringSubmatch(cfg); m != nil {
+		payload["bundlePolicy"] = m[1]
+	}
+	if urls := iceURLPattern.FindAllString(cfg, -1); len(urls) > 0 {
+		payload["iceServers"] = []interface{}{map[string]interface{}{"urls": urls}}
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// convertValue rebuilds the structured payload rtcstats would have logged
+// from the flattened updateLog value string.
+func convertValue(name, value string) json.RawMessage {
+	if value == "" {
+		return json.RawMessage("null")
+	}
+
+	var payload interface{}
+	switch {
+	case strings.HasSuffix(name, "OnFailure"):
+		// "OperationError: Failed to ..." carries the DOMException name first
+		failure := map[string]interface{}{"message": value}
+		if idx := strings.Index(value, ": "); idx > 0 && strings.HasSuffix(value[:idx], "Error") {
+			failure["name"] = value[:idx]
+			failure["message"] = value[idx+2:]
+		}
+		payload = failure
+
+	case name == "onicecandidate" || name == "addIceCandidate":
+		// "sdpMid: 0, sdpMLineIndex: 0, candidate: candidate:..."
+		fields := parseKeyValues(value)
+		cand := map[string]interface{}{"candidate": fields["candidate"]}
+		if mid, ok := fields["sdpMid"]; ok {
+			cand["sdpMid"] = mid
+		}
+		payload = cand
+
+	case strings.HasPrefix(value, "type: "):
+		// "type: offer, sdp: v=0\r\n..."
+		desc := make(map[string]interface{})
+		rest := strings.TrimPrefix(value, "type: ")
+		if idx := strings.Index(rest, ", sdp: "); idx >= 0 {
+			desc["type"] = rest[:idx]
+			desc["sdp"] = rest[idx+len(", sdp: "):]
+		} else {
+			desc["type"] = strings.TrimSpace(rest)
+		}
+		payload = desc
+
+	default:
+		// State names and anything that is already JSON
+		if json.Valid([]byte(value)) {
+			return json.RawMessage(value)
+		}
+		payload = value
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return json.RawMessage("null")
+	}
+	return data
+}
+
+// parseKeyValues splits "a: 1, b: 2, candidate: ..." into a map. The
+// candidate key is taken to the end of the string since it is always last.
+func parseKeyValues(s string) map[string]string {
+	result := make(map[string]string)
+	for s != "" {
+		idx := strings.Index(s, ": ")
+		if idx < 0 {
+			break
+		}
+		key := strings.TrimSpace(s[:idx])
+		s = s[idx+2:]
+		if key == "candidate" {
+			result[key] = s
+			break
+		}
+		end := strings.Index(s, ", ")
+		if end < 0 {
+			result[key] = s
+			break
+		}
+		result[key] = s[:end]
+		s = s[end+2:]
+	}
+	return result
+}
+
+// series is a decoded stats time series with one timestamp per value.
+type series struct {
+	field  string
+	start  int64
+	end    int64
+	values []interface{}
+}
+
+// convertStats rebuilds per-sample getstats reports from the per-field time
+// series. webrtc-internals only records start and end times, so sample
+// times are interpolated and then snapped to a common polling grid.
+func convertStats(scope string, stats map[string]statsSeries) []event.RawEvent {
+	entries := make(map[string][]series)
+	var gridEnd int64
+	var interval float64
+	longest := 0
+
+	// Visit series in key order so the polling interval is picked
+	// deterministically when several series are equally long
+	keys := make([]string, 0, len(stats))
+	for key := range stats {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := stats[key]
+		idx := strings.LastIndex(key, "-")
+		if idx <= 0 {
+			continue
+		}
+		entryID, field := key[:idx], key[idx+1:]
+		// Skip derived series such as "[bytesSent_in_bits/s]" and "[codec]"
+		if strings.HasPrefix(field, "[") {
+			continue
+		}
+
+		start, ok1 := parseTime(s.StartTime)
+		end, ok2 := parseTime(s.EndTime)
+		values := decodeValues(s.Values)
+		if !ok1 || !ok2 || len(values) == 0 {
+			continue
+		}
+
+		entries[entryID] = append(entries[entryID], series{field: field, start: start, end: end, values: values})
+		if end > gridEnd {
+			gridEnd = end
+		}
+		if len(values) > longest && len(values) > 1 {
+			longest = len(values)
+			interval = float64(end-start) / float64(len(values)-1)
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	if interval <= 0 {
+		interval = 1000
+	}
+
+	snap := func(ts float64) int64 {
+		steps := math.Round((float64(gridEnd) - ts) / interval)
+		return gridEnd - int64(steps*interval)
+	}
+
+	samples := make(map[int64]map[string]map[string]interface{})
+	for entryID, fields := range entries {
+		for _, s := range fields {
+			n := len(s.values)
+			for i, v := range s.values {
+				ts := float64(s.end)
+				if n > 1 {
+					ts = float64(s.start) + float64(i)*float64(s.end-s.start)/float64(n-1)
+				}
+				bucket := snap(ts)
+				report := samples[bucket]
+				if report == nil {
+					report = make(map[string]map[string]interface{})
+					samples[bucket] = report
+				}
+				entry := report[entryID]
+				if entry == nil {
+					entry = make(map[string]interface{})
+					report[entryID] = entry
+				}
+				entry[s.field] = v
+			}
+		}
+	}
+
+	times := make([]int64, 0, len(samples))
+	for ts := range samples {
+		times = append(times, ts)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+
+	events := make([]event.RawEvent, 0, len(times))
+	for _, ts := range times {
+		data, err := json.Marshal(samples[ts])
+		if err != nil {
+			continue
+		}
+		events = append(events, event.RawEvent{
+			Name:    "getstats",
+			Scope:   &scope,
+			Payload: data,
+			TS:      ts,
+		})
+	}
+	return events
+}
+
+// decodeValues accepts either a JSON array or a string containing one.
+func decodeValues(raw json.RawMessage) []interface{} {
+	var values []interface{}
+	if err := json.Unmarshal(raw, &values); err == nil {
+		return values
+	}
+	var encoded string
+	if err := json.Unmarshal(raw, &encoded); err != nil {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(encoded), &values); err != nil {
+		return nil
+	}
+	return values
+}
+
+// Time layouts seen in webrtc-internals exports across Chrome versions.
+// Locale strings carry no zone and are interpreted as UTC.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"1/2/2006, 3:04:05 PM",
+	"1/2/2006, 15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// parseTime converts an epoch-ms number or a date string to epoch ms.
+func parseTime(raw json.RawMessage) (int64, bool) {
+	var ms float64
+	if err := json.Unmarshal(raw, &ms); err == nil {
+		return int64(ms), true
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return 0, false
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UnixMilli(), true
+		}
+	}
+	return 0, false
+}
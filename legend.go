@@ -0,0 +1,52 @@
+package rtcstats
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"rtcstats/internal/ioutil"
+	"rtcstats/internal/legend"
+)
+
+// Legend lists the abbreviations, enum codes and scopes used in an output,
+// with their meanings. It is built with WithLegend.
+type Legend = legend.Legend
+
+// LegendEvent is the name of the record written first by WithLegend.
+const LegendEvent = legend.Event
+
+// legendBody holds output back until the legend record has been written
+// in front of it.
+type legendBody struct {
+	bytes.Buffer
+}
+
+// legendBuffer returns where the pipeline should write: w itself, or a
+// legendBody when a legend record must come first. Chunked output is not
+// held back; its legend is only returned in Result.
+func legendBuffer(w io.Writer, cfg options, chunks *chunkOutput) io.Writer {
+	if !cfg.legend || chunks != nil {
+		return w
+	}
+	return &legendBody{}
+}
+
+// writeLegend writes the legend record through enc, then the held back
+// output, to dest. cw is the pipeline's counting writer. It does nothing
+// unless legendBuffer held the output back.
+func writeLegend(dest io.Writer, cw *ioutil.CountWriter, enc Encoder, lb *legend.Builder) error {
+	body, ok := cw.W.(*legendBody)
+	if !ok {
+		return nil
+	}
+	cw.W = dest
+	if err := enc.Encode(CompressedEvent{Name: LegendEvent, Payload: lb.Legend()}); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	// The buffered output was counted as it was written
+	if _, err := dest.Write(body.Bytes()); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package rtcstats
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"rtcstats/internal/event"
+	"rtcstats/internal/ioutil"
+	"rtcstats/internal/merge"
+)
+
+// Participant maps a merge alias (p1, p2, ...) to the input it came from
+// and the user ID found in that input's joinRequest or track mapping.
+type Participant = merge.Participant
+
+// ParticipantsEvent is the name of the header record written by ProcessMany.
+const ParticipantsEvent = "merge.participants"
+
+// ProcessMany merges one dump per participant into a single timeline sorted
+// by ts and writes it to w. Every scope is prefixed with the participant's
+// alias ("p1/0-pub"), which also keeps getstats delta and sampling state
+// separate per participant. The first output record maps aliases to user IDs.
+func ProcessMany(inputPaths []string, w io.Writer, opts ...Option) (*Result, error) {
+	cfg := applyOpts(opts)
+
+	res, err := runMany(inputPaths, w, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	logResult(cfg.logger, res, "", "")
+	return res, nil
+}
+
+// MergeStats is the file-to-file form of ProcessMany. If outputPath is ""
+// or "-", it writes to stdout.
+func MergeStats(inputPaths []string, outputPath string, opts ...Option) (*Result, error) {
+	cfg := applyOpts(opts)
+
+	logConfig(cfg)
+
+	out, err := createChunkedOutput(outputPath, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := runMany(inputPaths, out, cfg)
+	if err != nil {
+		out.Close()
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		return nil, fmt.Errorf("writing output: %w", err)
+	}
+
+	logResult(cfg.logger, res, fmt.Sprintf("%d inputs", len(inputPaths)), outputPath)
+	return res, nil
+}
+
+func runMany(inputPaths []string, w io.Writer, cfg options) (*Result, error) {
+	if len(inputPaths) == 0 {
+		return nil, fmt.Errorf("no inputs to merge")
+	}
+	if err := checkOptions(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.tokenBudget > 0 {
+		return runWithBudget(w, cfg, func(w io.Writer, cfg options) (*Result, error) {
+			return runMany(inputPaths, w, cfg)
+		})
+	}
+
+	participants := make([]Participant, len(inputPaths))
+	aliases := make([]string, len(inputPaths))
+	for i, path := range inputPaths {
+		uid, err := scanUserID(path, cfg)
+		if err != nil {
+			return nil, err
+		}
+		aliases[i] = merge.Alias(i)
+		participants[i] = Participant{Alias: aliases[i], UserID: uid, Source: filepath.Base(path)}
+	}
+
+	inputs := make([]*input, 0, len(inputPaths))
+	defer func() {
+		for _, in := range inputs {
+			in.Close()
+		}
+	}()
+	sources := make([]event.Source, 0, len(inputPaths))
+	for _, path := range inputPaths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading input: %w", err)
+		}
+		defer f.Close()
+		in, err := openInput(f, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		inputs = append(inputs, in)
+		sources = append(sources, in.source)
+	}
+
+	var chunks *chunkOutput
+	if cfg.chunkTokens > 0 {
+		chunks = asChunkOutput(w)
+		w = chunks
+	}
+	body := legendBuffer(w, cfg, chunks)
+	cw := &ioutil.CountWriter{W: body}
+	src := watch(merge.NewSource(sources, aliases), func() int64 {
+		var n int64
+		for _, in := range inputs {
+			n += in.raw.Count
+		}
+		return n
+	}, cfg)
+	ps := newPseudonymizer(cfg)
+	pipeline, enc, lb := newPipeline(src, cw, cfg, chunks, ps)
+	record := pseudonymousParticipants(participants, ps)
+	if err := pipeline.WriteRecord(event.CompressedEvent{Name: ParticipantsEvent, Payload: record}); err != nil {
+		return nil, fmt.Errorf("processing: %w", err)
+	}
+	if err := pipeline.Run(); err != nil {
+		return nil, fmt.Errorf("processing: %w", err)
+	}
+	if err := writeLegend(w, cw, enc, lb); err != nil {
+		return nil, err
+	}
+
+	var inBytes, rawBytes int64
+	var events, reordered, late, inTokens int
+	var skipped []SkippedRecord
+	compression := inputs[0].compression.String()
+	for i, in := range inputs {
+		if in.compression.String() != compression {
+			compression = "mixed"
+		}
+		inBytes += in.counted.Count
+		rawBytes += in.raw.Count
+		inTokens += in.tokens.Total()
+		events += in.source.Count()
+		r, l := in.reorderCounts()
+		reordered += r
+		late += l
+		for _, s := range in.skipped() {
+			s.Input = aliases[i]
+			skipped = append(skipped, s)
+		}
+	}
+
+	res := buildResult(inBytes, cw.Count, events)
+	res.CompressedInputBytes = rawBytes
+	res.InputCompression = compression
+	res.SkippedRecords = skipped
+	res.ReorderedEvents, res.LateEvents = reordered, late
+	res.Participants = participants
+	setTokens(res, inTokens, enc)
+	if chunks != nil {
+		res.Chunks = chunkResults(pipeline.ChunkSizes(), chunks)
+	}
+	if lb != nil {
+		res.Legend = lb.Legend()
+	}
+	res.Pseudonyms = pseudonymTable(ps)
+	return res, nil
+}
+
+// scanUserID opens path and reads events until the local user ID is found.
+func scanUserID(path string, cfg options) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("reading input: %w", err)
+	}
+	defer f.Close()
+
+	// Malformed records are irrelevant here; the merge pass reports them
+	cfg.lenient = true
+	in, err := openInput(f, cfg)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	defer in.Close()
+
+	return merge.FindUserID(in.source), nil
+}
@@ -0,0 +1,106 @@
+package rtcstats
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"rtcstats/internal/event"
+)
+
+// Progress reports how far processing has got.
+type Progress struct {
+	Events    int   // input events read so far
+	BytesRead int64 // input bytes read so far, as read (before decompression)
+	TS        int64 // ts of the latest event read, epoch ms
+	Done      bool  // the input is exhausted; this is the last report
+}
+
+// DefaultProgressInterval is how often WithProgress reports by default.
+const DefaultProgressInterval = time.Second
+
+// WithProgress calls fn with the progress of processing at most once per
+// interval (see WithProgressInterval), and once more when the input is
+// exhausted. fn runs on the processing goroutine, so it should return
+// quickly. With WithTokenBudget every trial pass is reported; ProcessDir
+// reports each input separately, from several workers at once.
+func WithProgress(fn func(Progress)) Option {
+	return func(o *options) { o.progress = fn }
+}
+
+// WithProgressInterval sets how often WithProgress reports. An interval of
+// 0 reports after every event.
+func WithProgressInterval(d time.Duration) Option {
+	return func(o *options) { o.progressInterval = d }
+}
+
+// ProcessContext is Process with a context. Cancellation is checked before
+// each input event; once ctx is done, processing stops and the returned
+// error wraps ctx.Err(). w may then hold partial output.
+func ProcessContext(ctx context.Context, r io.Reader, w io.Writer, opts ...Option) (*Result, error) {
+	cfg := applyOpts(opts)
+	cfg.ctx = ctx
+
+	res, err := run(r, w, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	logResult(cfg.logger, res, "", "")
+	return res, nil
+}
+
+// watchSource wraps the pipeline's source to stop it when the context is
+// done and to report progress.
+type watchSource struct {
+	event.Source
+	ctx      context.Context // nil if not cancellable
+	bytes    func() int64    // input bytes read so far
+	progress func(Progress)  // nil if not reported
+	interval time.Duration
+	last     time.Time // time of the latest report
+	ts       int64     // ts of the latest event
+}
+
+// watch returns src wrapped for cfg's context and progress callback, or src
+// itself when there are neither.
+func watch(src event.Source, bytes func() int64, cfg options) event.Source {
+	if cfg.ctx == nil && cfg.progress == nil {
+		return src
+	}
+	return &watchSource{
+		Source:   src,
+		ctx:      cfg.ctx,
+		bytes:    bytes,
+		progress: cfg.progress,
+		interval: cfg.progressInterval,
+		last:     time.Now(),
+	}
+}
+
+func (s *watchSource) Next() (event.RawEvent, error) {
+	if s.ctx != nil {
+		if err := s.ctx.Err(); err != nil {
+			return event.RawEvent{}, err
+		}
+	}
+	ev, err := s.Source.Next()
+	switch {
+	case err == io.EOF:
+		s.report(true)
+	case err == nil:
+		s.ts = ev.TS
+		if time.Since(s.last) >= s.interval {
+			s.report(false)
+		}
+	}
+	return ev, err
+}
+
+func (s *watchSource) report(done bool) {
+	if s.progress == nil {
+		return
+	}
+	s.last = time.Now()
+	s.progress(Progress{Events: s.Count(), BytesRead: s.bytes(), TS: s.ts, Done: done})
+}
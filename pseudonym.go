@@ -0,0 +1,57 @@
+package rtcstats
+
+import "rtcstats/internal/transform"
+
+// WithPseudonymization replaces every user, session, device and group ID
+// in the output with a short alias that is the same throughout the output:
+// u1, u2, ... for users, s1, ... for sessions, d1, ... for devices and
+// g1, ... for groups, numbered in order of first appearance. With
+// ProcessMany the aliases are shared by all participants, including the
+// user IDs of the merge.participants record. Result.Pseudonyms maps the
+// aliases back to the IDs.
+func WithPseudonymization() Option {
+	return func(o *options) { o.pseudonymize = true }
+}
+
+// WithPseudonymSalt derives aliases from the first 64 bits of an
+// HMAC-SHA256 of the ID keyed by salt (u3f9a02c1d47e8b60) instead of
+// numbering them, so an ID gets the same alias in every output processed
+// with the same salt. Implies WithPseudonymization.
+func WithPseudonymSalt(salt string) Option {
+	return func(o *options) {
+		o.pseudonymize = true
+		o.pseudonymSalt = salt
+	}
+}
+
+// newPseudonymizer returns the Pseudonymizer for one output, or nil
+// without WithPseudonymization.
+func newPseudonymizer(cfg options) *transform.Pseudonymizer {
+	if !cfg.pseudonymize {
+		return nil
+	}
+	return transform.NewPseudonymizer(cfg.pseudonymSalt)
+}
+
+// pseudonymTable returns the IDs by alias, or nil without
+// WithPseudonymization.
+func pseudonymTable(ps *transform.Pseudonymizer) map[string]string {
+	if ps == nil {
+		return nil
+	}
+	return ps.Table()
+}
+
+// pseudonymousParticipants returns participants with their user IDs
+// replaced by aliases, for the merge.participants record.
+func pseudonymousParticipants(participants []Participant, ps *transform.Pseudonymizer) []Participant {
+	if ps == nil {
+		return participants
+	}
+	aliased := make([]Participant, len(participants))
+	for i, p := range participants {
+		p.UserID = ps.Alias(transform.IDFields["userId"], p.UserID)
+		aliased[i] = p
+	}
+	return aliased
+}
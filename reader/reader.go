@@ -0,0 +1,289 @@
+// Package reader parses compressed rtcstats output and rebuilds absolute
+// getstats values from it. Counter fields in the output are deltas, which
+// may be accumulated over samples dropped by adaptive sampling, and whole
+// categories may be replaced by "=" when unchanged. The reader integrates
+// the deltas back into absolute counters per scope and entry, resolves "="
+// markers, starts a scope over where a segment.start record says its peer
+// connection was replaced, and returns one time series per (scope,
+// category, entry).
+package reader
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"rtcstats/internal/event"
+	"rtcstats/internal/handlers"
+	"rtcstats/internal/ioutil"
+	"rtcstats/internal/processor"
+)
+
+// Point is one getstats sample of a series.
+type Point struct {
+	// TS is the epoch ms of the sample, or ms since the first event when
+	// the output only has delta timestamps.
+	TS int64
+
+	// Values maps original WebRTC field names to values: running totals
+	// for counters, the reported value for gauges. Counters are present
+	// once first reported; gauges only when non-zero in this sample.
+	Values map[string]float64
+
+	// Rates maps counter fields to their per-second rate since the
+	// previous point of the series.
+	Rates map[string]float64
+
+	// Unchanged is true when the sample was "=" (identical to the previous
+	// emitted sample) in the output.
+	Unchanged bool
+}
+
+// Series is the time series of one stats entry.
+type Series struct {
+	Scope    string
+	Category string // compressed key, e.g. "in_v"
+	Type     string // WebRTC stats type, e.g. "inbound-rtp/video"
+	Entry    int    // position within multi-entry categories (out_v, rtt, cp), else 0
+	Points   []Point
+
+	totals map[string]float64 // running counter totals
+}
+
+// Stats holds all series read from one output.
+type Stats struct {
+	Series []*Series // in order of first appearance
+
+	index map[string]*Series
+	last  map[string][]map[string]interface{} // scope + category → last emitted entries
+}
+
+// Get returns the series for (scope, category, entry), or nil.
+func (s *Stats) Get(scope, category string, entry int) *Series {
+	return s.index[seriesKey(scope, category, entry)]
+}
+
+// Read parses compressed output (JSONL, indented JSON, compact or tabular,
+// optionally gzip/zstd compressed) from r.
+//
+// Entries of multi-entry categories are matched across samples by their
+// position in the array. The compressor keeps an entry at the same
+// position for the life of its peer connection and writes an empty entry
+// in its place when it has nothing to report, so positions never shift.
+func Read(r io.Reader) (*Stats, error) {
+	dr, _, err := ioutil.NewDecompressReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading input: %w", err)
+	}
+	defer dr.Close()
+
+	s := &Stats{
+		index: make(map[string]*Series),
+		last:  make(map[string][]map[string]interface{}),
+	}
+	tables := handlers.NewDetabulator()
+	dec := event.NewDecoder(dr)
+	for {
+		ce, err := dec.Next()
+		if err == io.EOF {
+			return s, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch ce.Name {
+		case processor.ColumnsEvent:
+			tables.AddHeader(ce.Scope, ce.Payload)
+		case processor.ChunkEvent:
+			s.addChunkHeader(eventTS(ce), ce.Payload)
+		case processor.SegmentEvent:
+			s.addSegment(ce.Scope, ce.Payload)
+		case "getstats":
+			s.add(tables, ce.Scope, eventTS(ce), ce.Payload, false)
+		}
+	}
+}
+
+// ReadFile is Read for a file path.
+func ReadFile(path string) (*Stats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading input: %w", err)
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// eventTS returns the epoch ms of ce, or its ms since the first event.
+func eventTS(ce event.CompressedEvent) int64 {
+	if ce.TS == 0 && ce.DT != nil {
+		return *ce.DT
+	}
+	return ce.TS
+}
+
+// addChunkHeader restarts every series from the absolute getstats
+// baselines of a chunk header, so a chunk can be read on its own.
+func (s *Stats) addChunkHeader(ts int64, payload interface{}) {
+	header, _ := payload.(map[string]interface{})
+	scopes, _ := header["scopes"].(map[string]interface{})
+	for scope, st := range scopes {
+		events, _ := st.(map[string]interface{})
+		if baseline, ok := events["getstats"]; ok {
+			s.add(handlers.NewDetabulator(), scope, ts, baseline, true)
+		}
+	}
+}
+
+// addSegment starts the series of a replacing peer connection, and of the
+// one it replaces, over: the compressor forgets their delta state, so the
+// next counters are absolute.
+func (s *Stats) addSegment(scope string, payload interface{}) {
+	s.resetScope(scope)
+	if m, ok := payload.(map[string]interface{}); ok {
+		if prev, ok := m["prev"].(string); ok && prev != scope {
+			s.resetScope(prev)
+		}
+	}
+}
+
+// resetScope drops the running totals and last entries of scope.
+func (s *Stats) resetScope(scope string) {
+	for _, cat := range handlers.Categories {
+		delete(s.last, scope+"\x00"+cat.Key)
+	}
+	for _, sr := range s.Series {
+		if sr.Scope == scope {
+			sr.totals = make(map[string]float64)
+		}
+	}
+}
+
+// add integrates one getstats payload. Counters in an absolute payload
+// (a chunk baseline) replace the running totals instead of adding to them.
+func (s *Stats) add(tables *handlers.Detabulator, scope string, ts int64, payload interface{}, absolute bool) {
+	result, ok := payload.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	// Visit categories in output order so series are created deterministically
+	for _, cat := range handlers.Categories {
+		val, ok := result[cat.Key]
+		if !ok {
+			continue
+		}
+
+		lastKey := scope + "\x00" + cat.Key
+		unchanged := false
+		var entries []map[string]interface{}
+		if m, ok := val.(string); ok && m == "=" {
+			entries = s.last[lastKey]
+			unchanged = true
+		} else {
+			entries = tables.Entries(scope, cat.Key, val)
+			s.last[lastKey] = entries
+		}
+
+		fields := handlers.CategoryFields(cat.Key)
+		for i, entry := range entries {
+			s.series(scope, cat, i).add(ts, entry, fields, unchanged, absolute)
+		}
+	}
+}
+
+// series returns the series for (scope, cat, entry), creating it if needed.
+func (s *Stats) series(scope string, cat handlers.Category, entry int) *Series {
+	key := seriesKey(scope, cat.Key, entry)
+	if sr, ok := s.index[key]; ok {
+		return sr
+	}
+	sr := &Series{
+		Scope:    scope,
+		Category: cat.Key,
+		Type:     cat.Name,
+		Entry:    entry,
+		totals:   make(map[string]float64),
+	}
+	s.index[key] = sr
+	s.Series = append(s.Series, sr)
+	return sr
+}
+
+func seriesKey(scope, category string, entry int) string {
+	return fmt.Sprintf("%s\x00%s\x00%d", scope, category, entry)
+}
+
+// add appends a point built from one compressed entry. Omitted counters
+// are zero deltas; the first reported value of a counter is absolute, as
+// are all values when absolute is set.
+func (sr *Series) add(ts int64, entry map[string]interface{}, fields []handlers.Field, unchanged, absolute bool) {
+	p := Point{
+		TS:        ts,
+		Values:    make(map[string]float64, len(fields)),
+		Unchanged: unchanged,
+	}
+
+	var prev *Point
+	if n := len(sr.Points); n > 0 {
+		prev = &sr.Points[n-1]
+	}
+	elapsed := 0.0
+	if prev != nil {
+		elapsed = float64(ts-prev.TS) / 1000
+	}
+
+	for _, f := range fields {
+		v, present := entry[f.Short].(float64)
+		if !f.IsCounter {
+			if present {
+				p.Values[f.Original] = v
+			}
+			continue
+		}
+
+		total, seen := sr.totals[f.Original]
+		switch {
+		case absolute && !present:
+			delete(sr.totals, f.Original)
+			continue
+		case absolute:
+			total = v
+		case !present && !seen:
+			continue
+		default:
+			// Deltas carry 6 decimal places; round away float drift from summing
+			total = math.Round((total+v)*1e6) / 1e6
+		}
+		sr.totals[f.Original] = total
+		p.Values[f.Original] = total
+
+		if seen && prev != nil && elapsed > 0 {
+			if p.Rates == nil {
+				p.Rates = make(map[string]float64)
+			}
+			p.Rates[f.Original] = (total - prev.Values[f.Original]) / elapsed
+		}
+	}
+
+	// A chunk baseline repeats every scope's latest values; it is only a
+	// new sample if something changed
+	if absolute && prev != nil && sameValues(p.Values, prev.Values) {
+		return
+	}
+	sr.Points = append(sr.Points, p)
+}
+
+func sameValues(a, b map[string]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
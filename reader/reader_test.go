@@ -0,0 +1,229 @@
+package reader_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"rtcstats"
+	"rtcstats/reader"
+)
+
+// dump builds an rtcstats dump from [name, scope, payload] lines, one
+// second apart.
+func dump(lines ...[3]string) []byte {
+	var b strings.Builder
+	for i, l := range lines {
+		fmt.Fprintf(&b, "[%q, %q, %s, %d]\n", l[0], l[1], l[2], 1700000000000+int64(i)*1000)
+	}
+	return []byte(b.String())
+}
+
+// audio is a getstats payload with one outbound audio entry.
+func audio(bytesSent int) string {
+	return fmt.Sprintf(`{"oa": {"bytesSent": %d, "headerBytesSent": %d, "packetsSent": %d}}`, bytesSent, bytesSent/10, bytesSent/100)
+}
+
+func TestReadSegmentRestartsTotals(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []rtcstats.Option
+	}{
+		{"plain", nil},
+		{"include getstats", []rtcstats.Option{rtcstats.WithEventSelector(rtcstats.EventSelector{Include: []string{"getstats"}})}},
+	}
+	input := dump(
+		[3]string{"create", "0-pub", `{}`},
+		[3]string{"getstats", "0-pub", audio(1000)},
+		[3]string{"getstats", "0-pub", audio(2000)},
+		[3]string{"create", "0-pub", `{}`},
+		[3]string{"getstats", "0-pub", audio(500)},
+	)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, _, err := rtcstats.ProcessBytes(input, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stats, err := reader.Read(bytes.NewReader(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sr := stats.Get("0-pub", "out_a", 0)
+			if sr == nil {
+				t.Fatalf("no out_a series in %s", out)
+			}
+			want := []float64{1000, 2000, 500}
+			if len(sr.Points) != len(want) {
+				t.Fatalf("got %d points, want %d", len(sr.Points), len(want))
+			}
+			for i, p := range sr.Points {
+				if got := p.Values["bytesSent"]; got != want[i] {
+					t.Errorf("point %d: bytesSent = %v, want %v", i, got, want[i])
+				}
+			}
+		})
+	}
+}
+
+// chunks splits chunked output into its chunks.
+func chunks(out []byte) [][]byte {
+	var parts [][]byte
+	for _, line := range bytes.SplitAfter(out, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte(`{"n":"`+rtcstats.ChunkEvent+`"`)) || len(parts) == 0 {
+			parts = append(parts, nil)
+		}
+		parts[len(parts)-1] = append(parts[len(parts)-1], line...)
+	}
+	return parts
+}
+
+func TestReadChunkAfterFilteredSample(t *testing.T) {
+	var lines [][3]string
+	raw := make(map[int64]float64) // bytesSent by ts
+	bs := 0
+	for i := 0; i < 80; i++ {
+		bs += 1000 + 37*i
+		raw[1700000000000+int64(len(lines))*1000] = float64(bs)
+		lines = append(lines, [3]string{"getstats", "0-pub", audio(bs)})
+		if i%2 == 1 {
+			lines = append(lines, [3]string{"connectionstatechange", "0-pub", `"connected"`})
+		}
+	}
+
+	// Drop every other sample. The dropped ones come right before the
+	// state changes, which are where most chunks start.
+	n := 0
+	dropOdd := func(e rtcstats.EventInfo) bool {
+		if e.Name != "getstats" {
+			return true
+		}
+		n++
+		return n%2 == 1
+	}
+	out, _, err := rtcstats.ProcessBytes(dump(lines...), rtcstats.WithEventFilter(dropOdd), rtcstats.WithChunking(300))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checked := 0
+	for i, part := range chunks(out) {
+		stats, err := reader.Read(bytes.NewReader(part))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sr := stats.Get("0-pub", "out_a", 0)
+		if sr == nil {
+			continue
+		}
+		// The header's baseline holds the dropped sample the next one is
+		// compressed against, so that one comes out right
+		p := sr.Points[0]
+		if _, ok := raw[p.TS]; !ok && len(sr.Points) > 1 {
+			p = sr.Points[1]
+			checked++
+		}
+		if want, ok := raw[p.TS]; ok && p.Values["bytesSent"] != want {
+			t.Errorf("chunk %d: bytesSent at %d = %v, want %v", i+1, p.TS, p.Values["bytesSent"], want)
+		}
+	}
+	if checked < 3 {
+		t.Fatalf("only %d chunks start with a state change, want several", checked)
+	}
+}
+
+func TestReadRoundTrip(t *testing.T) {
+	// raw holds the absolute value of each counter the dump reports, by
+	// scope, category, entry, field and ts
+	type key struct {
+		scope, category string
+		entry           int
+		field           string
+	}
+	raw := make(map[key]map[int64]float64)
+	set := func(k key, ts int64, v int) {
+		if raw[k] == nil {
+			raw[k] = make(map[int64]float64)
+		}
+		raw[k][ts] = float64(v)
+	}
+
+	var lines [][3]string
+	ts := func() int64 { return 1700000000000 + int64(len(lines))*1000 }
+	lines = append(lines, [3]string{"create", "0-pub", `{}`}, [3]string{"create", "0-sub", `{}`})
+	var sent, frames, received, lost int
+	for i := 0; i < 60; i++ {
+		// Audio is flat for samples 20-39, so out_a is "=" when sampling
+		if i < 20 || i >= 40 {
+			sent += 1000 + 13*i
+			received += 900 + 7*i
+		}
+		frames += 30
+		if i == 45 {
+			lost += 25 // interesting for the sampler
+		}
+		set(key{"0-pub", "out_a", 0, "bytesSent"}, ts(), sent)
+		set(key{"0-pub", "out_v", 0, "bytesSent"}, ts(), 3*sent+100*frames)
+		set(key{"0-pub", "out_v", 0, "framesEncoded"}, ts(), frames)
+		set(key{"0-pub", "out_v", 1, "bytesSent"}, ts(), sent/2)
+		set(key{"0-pub", "out_v", 1, "framesEncoded"}, ts(), frames/2)
+		lines = append(lines, [3]string{"getstats", "0-pub", fmt.Sprintf(
+			`{"oa": {"bytesSent": %d, "headerBytesSent": %d, "packetsSent": %d},
+			  "ov1": {"bytesSent": %d, "framesEncoded": %d},
+			  "ov2": {"bytesSent": %d, "framesEncoded": %d}}`,
+			sent, sent/10, sent/100, 3*sent+100*frames, frames, sent/2, frames/2)})
+
+		set(key{"0-sub", "in_a", 0, "bytesReceived"}, ts(), received)
+		set(key{"0-sub", "in_a", 0, "concealedSamples"}, ts(), lost)
+		lines = append(lines, [3]string{"getstats", "0-sub", fmt.Sprintf(
+			`{"ia": {"bytesReceived": %d, "concealedSamples": %d, "audioLevel": 0.5}}`, received, lost)})
+	}
+	input := dump(lines...)
+
+	tests := []struct {
+		name       string
+		opts       []rtcstats.Option
+		suppressed bool // output has "=" samples
+	}{
+		{"plain", nil, false},
+		{"sampled", []rtcstats.Option{rtcstats.WithSampling()}, true},
+		{"tabular", []rtcstats.Option{rtcstats.WithTabularStats()}, false},
+		{"sampled tabular", []rtcstats.Option{rtcstats.WithSampling(), rtcstats.WithTabularStats()}, true},
+		{"chunked", []rtcstats.Option{rtcstats.WithChunking(400)}, false},
+		{"chunked sampled tabular", []rtcstats.Option{rtcstats.WithChunking(400), rtcstats.WithSampling(), rtcstats.WithTabularStats()}, true},
+		{"compact", []rtcstats.Option{rtcstats.WithOutputFormat(rtcstats.FormatCompact)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, _, err := rtcstats.ProcessBytes(input, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stats, err := reader.Read(bytes.NewReader(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			suppressed := false
+			for k, values := range raw {
+				sr := stats.Get(k.scope, k.category, k.entry)
+				if sr == nil || len(sr.Points) < 5 {
+					t.Fatalf("%v: too few points in %v", k, sr)
+				}
+				for _, p := range sr.Points {
+					suppressed = suppressed || p.Unchanged
+					want, ok := values[p.TS]
+					if !ok {
+						t.Fatalf("%v: point at %d is not a sample", k, p.TS)
+					}
+					if got := p.Values[k.field]; got != want {
+						t.Errorf("%v at %d = %v, want %v", k, p.TS, got, want)
+					}
+				}
+			}
+			if suppressed != tt.suppressed {
+				t.Errorf("output has \"=\" samples: %v, want %v", suppressed, tt.suppressed)
+			}
+		})
+	}
+}
@@ -1,15 +1,26 @@
 package rtcstats
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"rtcstats/internal/event"
+	"rtcstats/internal/handlers"
 	"rtcstats/internal/ioutil"
+	"rtcstats/internal/legend"
 	"rtcstats/internal/processor"
 	"rtcstats/internal/sampling"
+	"rtcstats/internal/statsdelta"
+	"rtcstats/internal/tokens"
+	"rtcstats/internal/transform"
+	"rtcstats/internal/webrtcinternals"
 )
 
 // TimestampMode controls how timestamps appear in output.
@@ -21,12 +32,101 @@ const (
 	TSBoth     TimestampMode = event.TSBoth
 )
 
+// StatsDeltaMode controls decoding of getstats payloads that the rtcstats
+// client delta-compressed (only fields changed since the previous report).
+type StatsDeltaMode = statsdelta.Mode
+
+const (
+	StatsDeltaAuto StatsDeltaMode = statsdelta.ModeAuto // detect from payload markers (default)
+	StatsDeltaOff  StatsDeltaMode = statsdelta.ModeOff
+	StatsDeltaOn   StatsDeltaMode = statsdelta.ModeOn
+)
+
+// OutputFormat selects a built-in output encoder.
+type OutputFormat = event.OutputFormat
+
+const (
+	FormatJSONL   OutputFormat = event.FormatJSONL      // one JSON object per line (default)
+	FormatJSON    OutputFormat = event.FormatPrettyJSON // indented JSON objects
+	FormatCompact OutputFormat = event.FormatCompact    // positional arrays [n,s,p,ts|dt]
+)
+
+// CompressedEvent is one output record as passed to an Encoder.
+type CompressedEvent = event.CompressedEvent
+
+// Encoder serializes output records. Implement it to plug in a custom
+// output format with WithEncoder.
+type Encoder = event.Encoder
+
+// ColumnsEvent is the name of the column header records written by
+// WithTabularStats.
+const ColumnsEvent = processor.ColumnsEvent
+
+// SegmentEvent is the name of the marker record written before the first
+// event of a peer connection that replaces an earlier one after a
+// reconnect (1-pub after 0-pub, or a second create in the same scope). Its
+// payload gives the reason (r), the replaced scope (prev) and the ms since
+// the replaced connection went down (gap). The replaced connection's
+// getstats, sampling and chunk state is dropped there, so no delta spans
+// two connections.
+const SegmentEvent = processor.SegmentEvent
+
+// IncompleteEvent is the name of the records WithCorrelation writes for
+// requests that never completed.
+const IncompleteEvent = processor.IncompleteEvent
+
+// Tokenizer counts LLM tokens in a piece of text. The default is an offline
+// approximation of a BPE tokenizer; set a model-specific one with WithTokenizer.
+type Tokenizer = tokens.Tokenizer
+
+// SkippedRecord describes an input record dropped by lenient parsing.
+type SkippedRecord = event.SkippedRecord
+
 // Result holds processing statistics.
 type Result struct {
-	InputBytes  int64
+	InputBytes  int64 // uncompressed input size
 	OutputBytes int64
 	Reduction   float64 // 0–1 fraction
 	EventCount  int
+
+	// CompressedInputBytes is the input size as read, before decompression.
+	// Equal to InputBytes when the input was not compressed.
+	CompressedInputBytes int64
+	InputCompression     string // "gzip", "zstd" or "none"
+
+	// SkippedRecords lists malformed records dropped by WithLenientParsing.
+	SkippedRecords []SkippedRecord
+
+	// ReorderedEvents counts events that arrived with a ts older than an
+	// earlier record and were sorted by WithReorderWindow. LateEvents counts
+	// those that fell outside the window and remain out of order.
+	ReorderedEvents int
+	LateEvents      int
+
+	// Participants maps aliases to inputs and user IDs (ProcessMany only).
+	Participants []Participant
+
+	// Estimated LLM tokens, counted with the Tokenizer set by WithTokenizer.
+	InputTokens      int
+	OutputTokens     int
+	TokensByEvent    map[string]int // output tokens per event name
+	TokensByCategory map[string]int // output tokens per getstats category (out_v, in_a, ...)
+
+	// Budget reports the sampling settings chosen by WithTokenBudget.
+	Budget *BudgetResult
+
+	// Chunks lists the chunks written with WithChunking.
+	Chunks []Chunk
+
+	// Legend explains the abbreviations, enum codes and scopes used in the
+	// output (WithLegend only).
+	Legend *Legend
+
+	// Pseudonyms maps the aliases in the output back to the IDs they
+	// replace (WithPseudonymization only). It undoes the pseudonymization,
+	// so keep it with the input, not the output. It is left out of the
+	// JSON encoding of Result, so the ProcessDir report never holds it.
+	Pseudonyms map[string]string `json:"-"`
 }
 
 // Logger receives processing stats. Compatible with log.Printf.
@@ -49,9 +149,35 @@ type Option func(*options)
 
 type options struct {
 	tsMode   TimestampMode
-	pretty   bool
+	format   OutputFormat
+	encoder  func(w io.Writer) Encoder
 	logger   Logger
 	sampling *sampling.Config
+	lenient  bool
+
+	statsDelta    StatsDeltaMode
+	reorderWindow time.Duration
+	tabular       bool
+	tokenizer     Tokenizer
+	tokenBudget   int
+	chunkTokens   int
+	legend        bool
+	correlate     bool
+	collapse      *processor.Collapsing
+	pseudonymize  bool
+	pseudonymSalt string
+	filters       []func(e EventInfo) bool
+	selectors     []EventSelector // filters given with WithEventSelector
+
+	handlers         []func(r *handlers.Registry)
+	replacesGetStats bool
+
+	ctx              context.Context // ProcessContext only
+	progress         func(Progress)
+	progressInterval time.Duration
+
+	workers   int  // ProcessDir only
+	reprocess bool // ProcessDir only
 }
 
 // WithTimestampMode sets absolute, delta, or both.
@@ -60,8 +186,21 @@ func WithTimestampMode(mode TimestampMode) Option {
 }
 
 // WithPrettyPrint enables indented JSON output.
+// Equivalent to WithOutputFormat(FormatJSON).
 func WithPrettyPrint() Option {
-	return func(o *options) { o.pretty = true }
+	return func(o *options) { o.format = FormatJSON }
+}
+
+// WithOutputFormat selects a built-in encoder: FormatJSONL (default),
+// FormatJSON, or FormatCompact.
+func WithOutputFormat(f OutputFormat) Option {
+	return func(o *options) { o.format = f }
+}
+
+// WithEncoder sets a constructor for a custom Encoder, overriding
+// WithOutputFormat.
+func WithEncoder(fn func(w io.Writer) Encoder) Option {
+	return func(o *options) { o.encoder = fn }
 }
 
 // WithLogger sets a Logger to receive file-size stats after processing.
@@ -103,8 +242,137 @@ func WithSamplingContext(before, after int) Option {
 	}
 }
 
+// WithLenientParsing skips malformed records (too few elements, bad
+// timestamps, truncated JSON) instead of aborting. Skipped records are
+// reported in Result.SkippedRecords.
+func WithLenientParsing() Option {
+	return func(o *options) { o.lenient = true }
+}
+
+// WithStatsDeltaMode sets how client delta-compressed getstats payloads are
+// handled. StatsDeltaAuto (default) starts decoding once a payload carries
+// the client's delta markers.
+func WithStatsDeltaMode(mode StatsDeltaMode) Option {
+	return func(o *options) { o.statsDelta = mode }
+}
+
+// WithReorderWindow buffers events and sorts them by ts within the given
+// window before handling, fixing records that were flushed late (e.g.
+// buffered SFU events). Memory use is bounded by the events in the window.
+func WithReorderWindow(window time.Duration) Option {
+	return func(o *options) { o.reorderWindow = window }
+}
+
+// WithTabularStats emits getstats payloads as positional value rows. The
+// column order of each (scope, category) series is announced once in a
+// ColumnsEvent record written just before the first row that uses it.
+func WithTabularStats() Option {
+	return func(o *options) { o.tabular = true }
+}
+
+// WithTokenizer sets the Tokenizer used for the token counts in Result.
+func WithTokenizer(t Tokenizer) Option {
+	return func(o *options) { o.tokenizer = t }
+}
+
+// WithTokenBudget makes processing pick the highest-fidelity sampling
+// settings (interval, context window, steady-state suppression) whose output
+// fits in n tokens, as counted by the Tokenizer. It overrides the other
+// sampling options; interesting moments are always kept at full resolution.
+// The input is held in memory so it can be processed more than once, and
+// the chosen settings are reported in Result.Budget.
+func WithTokenBudget(n int) Option {
+	return func(o *options) { o.tokenBudget = n }
+}
+
+// WithChunking splits the output into chunks of at most n tokens, for
+// calls too long for one prompt. Each chunk starts with a ChunkEvent record
+// holding what earlier chunks established: the active scopes, their latest
+// connection states and SDP digests, and a full getstats baseline of
+// absolute values. Deltas in a chunk are relative to its baseline, so any
+// chunk can be analysed on its own. ProcessStats writes chunk i to a
+// numbered file (call.jsonl → call.001.jsonl); other entry points write the
+// chunks one after the other. A single record larger than n still gets a
+// chunk of its own. Cannot be combined with WithTokenBudget.
+func WithChunking(n int) Option {
+	return func(o *options) { o.chunkTokens = n }
+}
+
+// WithLegend builds a dictionary of only the abbreviations, enum codes and
+// scopes that appear in the output, and writes it as the first record (a
+// LegendEvent) so the output explains itself. The rest of the output is
+// held in memory until processing ends. With WithChunking the legend is
+// only returned in Result.Legend.
+func WithLegend() Option {
+	return func(o *options) { o.legend = true }
+}
+
+// WithCorrelation pairs the peer connection requests of each scope
+// (createOffer, createAnswer, setLocalDescription, setRemoteDescription,
+// addIceCandidate) with their OnSuccess or OnFailure results. Both get the
+// same rid, and the result gets dur, the ms since its request. A
+// setLocalDescription continues the rid of the createOffer or createAnswer
+// it applies. A request still unanswered when its peer connection closes,
+// or when the input ends, is flagged with an IncompleteEvent record.
+func WithCorrelation() Option {
+	return func(o *options) { o.correlate = true }
+}
+
+// DefaultCollapseWindow is how long a run of repeated events may last
+// when collapsing, unless set with WithCollapseWindow.
+const DefaultCollapseWindow = time.Minute
+
+// WithCollapsing merges consecutive repeated events into one record: an
+// event with the same name, scope and compressed payload as the record
+// just before it is counted instead of written. The record, written in the
+// place of the run's first event, gets rep, the number of events, and
+// span, the ms from the first to the last. Any other record ends the run,
+// as does the collapse window. getstats and records correlated by
+// WithCorrelation (those with a rid) are never merged.
+func WithCollapsing() Option {
+	return func(o *options) {
+		if o.collapse == nil {
+			o.collapse = &processor.Collapsing{Window: DefaultCollapseWindow.Milliseconds()}
+		}
+	}
+}
+
+// WithCollapseWindow sets how long a run of repeated events may last; a
+// repeat that comes later starts a new record. Implies WithCollapsing.
+func WithCollapseWindow(d time.Duration) Option {
+	return func(o *options) {
+		WithCollapsing()(o)
+		o.collapse.Window = d.Milliseconds()
+	}
+}
+
+// WithICEAggregation merges runs of onicecandidate and addIceCandidate
+// events of a scope whatever their candidates, except correlated requests
+// (see WithCollapsing). The record gives n, the number of candidates, and
+// cand, their counts by type and transport, e.g.
+// [{"t":"host","tr":"udp","n":3}]. An end-of-candidates event ends the
+// run. Implies WithCollapsing.
+func WithICEAggregation() Option {
+	return func(o *options) {
+		WithCollapsing()(o)
+		o.collapse.ICE = true
+	}
+}
+
+// WithWorkers sets how many inputs ProcessDir processes at once. The
+// default is the number of CPUs.
+func WithWorkers(n int) Option {
+	return func(o *options) { o.workers = n }
+}
+
+// WithReprocess makes ProcessDir process every input, including those an
+// earlier run already processed.
+func WithReprocess() Option {
+	return func(o *options) { o.reprocess = true }
+}
+
 func applyOpts(opts []Option) options {
-	o := options{tsMode: TSAbsolute}
+	o := options{tsMode: TSAbsolute, tokenizer: tokens.Approx{}, progressInterval: DefaultProgressInterval}
 	for _, fn := range opts {
 		fn(&o)
 	}
@@ -112,103 +380,337 @@ func applyOpts(opts []Option) options {
 }
 
 // ProcessStats reads inputPath, processes events, and writes to outputPath.
-// If outputPath is "" or "-", it writes to stdout.
+// If inputPath is "-", it reads from stdin. If outputPath is "" or "-", it writes to stdout.
+// Gzip and zstd input is decompressed transparently; output is compressed
+// when outputPath ends in ".gz" or ".zst".
 func ProcessStats(inputPath, outputPath string, opts ...Option) (*Result, error) {
 	cfg := applyOpts(opts)
 
-	// Log the sampling configuration
-	if cfg.logger != nil {
-		switch {
-		case cfg.sampling != nil && cfg.sampling.SteadyState:
-			cfg.logger.Printf("Processing with Adaptive Sampling + Steady State Suppression (interval=%d, context=%d/%d)",
-				cfg.sampling.Interval, cfg.sampling.ContextBefore, cfg.sampling.ContextAfter)
-		case cfg.sampling != nil:
-			cfg.logger.Printf("Processing with Adaptive Sampling (interval=%d, context=%d/%d)",
-				cfg.sampling.Interval, cfg.sampling.ContextBefore, cfg.sampling.ContextAfter)
-		default:
-			cfg.logger.Printf("Processing with no sampling")
+	logConfig(cfg)
+
+	res, _, err := processFile(inputPath, outputPath, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	logResult(cfg.logger, res, inputPath, outputPath)
+	return res, nil
+}
+
+// processFile is ProcessStats without logging. It also returns the files
+// it wrote to, which hold partial output if it fails.
+func processFile(inputPath, outputPath string, cfg options) (*Result, []string, error) {
+	var src io.Reader = os.Stdin
+	if inputPath != "-" {
+		inFile, err := os.Open(inputPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading input: %w", err)
 		}
+		defer inFile.Close()
+		src = inFile
 	}
-	
-	inputData, err := os.ReadFile(inputPath)
+
+	out, err := createChunkedOutput(outputPath, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("reading input: %w", err)
+		return nil, nil, err
 	}
-	inputSize := int64(len(inputData))
 
-	reader, err := event.NewReader(inputData)
+	res, err := run(src, out, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("parsing input: %w", err)
+		out.Close()
+		return nil, writtenPaths(out, outputPath), err
+	}
+	if err := out.Close(); err != nil {
+		return nil, writtenPaths(out, outputPath), fmt.Errorf("writing output: %w", err)
 	}
+	return res, writtenPaths(out, outputPath), nil
+}
 
-	var dest io.Writer = os.Stdout
-	var outFile *os.File
-	if outputPath != "" && outputPath != "-" {
-		outFile, err = os.Create(outputPath)
+// writtenPaths returns the files out, opened for outputPath, wrote to.
+func writtenPaths(out io.WriteCloser, outputPath string) []string {
+	if c, ok := out.(*chunkOutput); ok {
+		return c.paths
+	}
+	if outputPath == "" || outputPath == "-" {
+		return nil
+	}
+	return []string{outputPath}
+}
+
+// createChunkedOutput is createOutput, except that chunked output to a
+// file is written to one numbered file per chunk.
+func createChunkedOutput(outputPath string, cfg options) (io.WriteCloser, error) {
+	if cfg.chunkTokens > 0 && outputPath != "" && outputPath != "-" {
+		return &chunkOutput{path: outputPath}, nil
+	}
+	return createOutput(outputPath)
+}
+
+// Process reads from r, processes events, and writes to w.
+// Events are decoded and emitted one at a time, so memory use does not
+// grow with the size of the input. Gzip and zstd input is detected by its
+// magic bytes and decompressed transparently.
+func Process(r io.Reader, w io.Writer, opts ...Option) (*Result, error) {
+	cfg := applyOpts(opts)
+
+	res, err := run(r, w, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	logResult(cfg.logger, res, "", "")
+	return res, nil
+}
+
+// ProcessBytes processes input bytes in memory and returns the output bytes.
+func ProcessBytes(input []byte, opts ...Option) ([]byte, *Result, error) {
+	cfg := applyOpts(opts)
+
+	var buf bytes.Buffer
+	res, err := run(bytes.NewReader(input), &buf, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logResult(cfg.logger, res, "", "")
+	return buf.Bytes(), res, nil
+}
+
+// run streams events from r through a pipeline into w, counting bytes on both sides.
+func run(r io.Reader, w io.Writer, cfg options) (*Result, error) {
+	if err := checkOptions(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.tokenBudget > 0 {
+		data, err := io.ReadAll(r)
 		if err != nil {
-			return nil, fmt.Errorf("creating output file: %w", err)
+			return nil, fmt.Errorf("reading input: %w", err)
 		}
-		defer outFile.Close()
-		dest = outFile
+		return runWithBudget(w, cfg, func(w io.Writer, cfg options) (*Result, error) {
+			return run(bytes.NewReader(data), w, cfg)
+		})
 	}
 
-	cw := &ioutil.CountWriter{W: dest}
-	pipeline := processor.NewPipeline(reader, cw, cfg.tsMode, cfg.pretty, cfg.sampling)
+	in, err := openInput(r, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	var chunks *chunkOutput
+	if cfg.chunkTokens > 0 {
+		chunks = asChunkOutput(w)
+		w = chunks
+	}
+	body := legendBuffer(w, cfg, chunks)
+	cw := &ioutil.CountWriter{W: body}
+	src := watch(in.source, func() int64 { return in.raw.Count }, cfg)
+	ps := newPseudonymizer(cfg)
+	pipeline, enc, lb := newPipeline(src, cw, cfg, chunks, ps)
 	if err := pipeline.Run(); err != nil {
 		return nil, fmt.Errorf("processing: %w", err)
 	}
+	if err := writeLegend(w, cw, enc, lb); err != nil {
+		return nil, err
+	}
 
-	res := buildResult(inputSize, cw.Count, len(reader.AllEvents()))
-	logResult(cfg.logger, res, inputPath, outputPath)
+	res := buildResult(in.counted.Count, cw.Count, in.source.Count())
+	res.CompressedInputBytes = in.raw.Count
+	res.InputCompression = in.compression.String()
+	res.SkippedRecords = in.skipped()
+	res.ReorderedEvents, res.LateEvents = in.reorderCounts()
+	setTokens(res, in.tokens.Total(), enc)
+	if chunks != nil {
+		res.Chunks = chunkResults(pipeline.ChunkSizes(), chunks)
+	}
+	if lb != nil {
+		res.Legend = lb.Legend()
+	}
+	res.Pseudonyms = pseudonymTable(ps)
 	return res, nil
 }
 
-// Process reads from r, processes events, and writes to w.
-func Process(r io.Reader, w io.Writer, opts ...Option) (*Result, error) {
-	cfg := applyOpts(opts)
+// newPipeline builds a pipeline whose output encoder also counts tokens
+// and, with WithLegend, collects the legend. chunks, if not nil, is the
+// chunkOutput underlying w; ps, if not nil, replaces IDs with aliases.
+func newPipeline(src event.Source, w io.Writer, cfg options, chunks *chunkOutput, ps *transform.Pseudonymizer) (*processor.Pipeline, *tokens.Encoder, *legend.Builder) {
+	newEnc := cfg.encoder
+	if newEnc == nil {
+		newEnc = func(w io.Writer) Encoder { return event.NewEncoder(w, cfg.format) }
+	}
 
-	inputData, err := io.ReadAll(r)
+	var chunking *processor.Chunking
+	if chunks != nil {
+		chunking = newChunking(cfg, newEnc, chunks)
+	}
+
+	var lb *legend.Builder
+	if cfg.legend {
+		lb = legend.NewBuilder()
+	}
+
+	var enc *tokens.Encoder
+	pipeline := processor.NewPipeline(src, w, processor.Config{
+		TSMode:     cfg.tsMode,
+		Sampling:   cfg.sampling,
+		StatsDelta: cfg.statsDelta,
+		Tabular:    cfg.tabular,
+		Correlate:  cfg.correlate,
+		Collapsing: cfg.collapse,
+		Encoder: func(w io.Writer) event.Encoder {
+			enc = tokens.NewEncoder(w, newEnc, cfg.tokenizer)
+			if lb != nil {
+				return legend.NewEncoder(enc, lb)
+			}
+			return enc
+		},
+		Chunking:      chunking,
+		Filter:        eventFilter(cfg),
+		Register:      registerHandlers(cfg),
+		Pseudonymizer: ps,
+	})
+	return pipeline, enc, lb
+}
+
+// setTokens copies token counts into res.
+func setTokens(res *Result, inputTokens int, enc *tokens.Encoder) {
+	res.InputTokens = inputTokens
+	res.OutputTokens = enc.Total()
+	res.TokensByEvent = enc.ByEvent()
+	res.TokensByCategory = enc.ByCategory()
+}
+
+// input is an opened, decompressed event stream with byte counters on
+// both sides of decompression.
+type input struct {
+	raw         *ioutil.CountReader // bytes as read
+	counted     *ioutil.CountReader // bytes after decompression
+	dr          io.ReadCloser
+	compression ioutil.Compression
+	tokens      *tokens.Counter // tokens after decompression
+	reader      event.Source    // decoded input, before reordering
+	reorder     *event.ReorderSource
+	source      event.Source // what the pipeline consumes
+}
+
+func openInput(r io.Reader, cfg options) (*input, error) {
+	in := &input{raw: &ioutil.CountReader{R: r}}
+	dr, compression, err := ioutil.NewDecompressReader(in.raw)
 	if err != nil {
 		return nil, fmt.Errorf("reading input: %w", err)
 	}
-	inputSize := int64(len(inputData))
+	in.dr = dr
+	in.compression = compression
 
-	reader, err := event.NewReader(inputData)
+	in.tokens = tokens.NewCounter(cfg.tokenizer)
+	in.counted = &ioutil.CountReader{R: io.TeeReader(dr, in.tokens)}
+	in.reader, err = openSource(in.counted, cfg)
 	if err != nil {
+		dr.Close()
 		return nil, fmt.Errorf("parsing input: %w", err)
 	}
 
-	cw := &ioutil.CountWriter{W: w}
-	pipeline := processor.NewPipeline(reader, cw, cfg.tsMode, cfg.pretty, cfg.sampling)
-	if err := pipeline.Run(); err != nil {
-		return nil, fmt.Errorf("processing: %w", err)
+	in.source = in.reader
+	if cfg.reorderWindow > 0 {
+		in.reorder = event.NewReorderSource(in.reader, cfg.reorderWindow.Milliseconds())
+		in.source = in.reorder
 	}
+	return in, nil
+}
 
-	res := buildResult(inputSize, cw.Count, len(reader.AllEvents()))
-	logResult(cfg.logger, res, "", "")
-	return res, nil
+func (in *input) Close() error {
+	return in.dr.Close()
 }
 
-// ProcessBytes processes input bytes in memory and returns the output bytes.
-func ProcessBytes(input []byte, opts ...Option) ([]byte, *Result, error) {
-	cfg := applyOpts(opts)
+// skipped returns records dropped by lenient parsing, if the source supports it.
+func (in *input) skipped() []SkippedRecord {
+	if er, ok := in.reader.(*event.Reader); ok {
+		return er.Skipped()
+	}
+	return nil
+}
+
+// reorderCounts returns the reordered and late event counts.
+func (in *input) reorderCounts() (int, int) {
+	if in.reorder == nil {
+		return 0, 0
+	}
+	return in.reorder.Reordered(), in.reorder.Late()
+}
 
-	inputSize := int64(len(input))
-	reader, err := event.NewReader(input)
+// openSource picks an event source for r based on its first byte: a stream
+// of rtcstats arrays, or a chrome://webrtc-internals dump object.
+func openSource(r io.Reader, cfg options) (event.Source, error) {
+	br := bufio.NewReader(r)
+	format, err := event.DetectFormat(br)
 	if err != nil {
-		return nil, nil, fmt.Errorf("parsing input: %w", err)
+		return nil, err
+	}
+	if format == event.FormatWebRTCInternals {
+		return webrtcinternals.NewReader(br)
 	}
+	return event.NewReader(br, cfg.lenient)
+}
 
-	var buf bytes.Buffer
-	cw := &ioutil.CountWriter{W: &buf}
-	pipeline := processor.NewPipeline(reader, cw, cfg.tsMode, cfg.pretty, cfg.sampling)
-	if err := pipeline.Run(); err != nil {
-		return nil, nil, fmt.Errorf("processing: %w", err)
+// output is the destination opened by createOutput.
+type output struct {
+	io.WriteCloser          // compressor (or pass-through) over file
+	file           *os.File // nil for stdout
+}
+
+// createOutput opens outputPath for writing ("" or "-" means stdout),
+// compressing when the path ends in .gz or .zst.
+func createOutput(outputPath string) (*output, error) {
+	out := &output{}
+	var dest io.Writer = os.Stdout
+	if outputPath != "" && outputPath != "-" {
+		f, err := os.Create(outputPath)
+		if err != nil {
+			return nil, fmt.Errorf("creating output file: %w", err)
+		}
+		out.file = f
+		dest = f
 	}
 
-	res := buildResult(inputSize, cw.Count, len(reader.AllEvents()))
-	logResult(cfg.logger, res, "", "")
-	return buf.Bytes(), res, nil
+	zw, err := ioutil.NewCompressWriter(dest, ioutil.CompressionForPath(outputPath))
+	if err != nil {
+		if out.file != nil {
+			out.file.Close()
+		}
+		return nil, fmt.Errorf("creating output file: %w", err)
+	}
+	out.WriteCloser = zw
+	return out, nil
+}
+
+// Close flushes the compressor and closes the file.
+func (o *output) Close() error {
+	err := o.WriteCloser.Close()
+	if o.file != nil {
+		if cerr := o.file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// logConfig logs the sampling configuration before processing.
+func logConfig(cfg options) {
+	if cfg.logger == nil {
+		return
+	}
+	switch {
+	case cfg.tokenBudget > 0:
+		cfg.logger.Printf("Processing with token budget %d (searching sampling settings)", cfg.tokenBudget)
+	case cfg.sampling != nil && cfg.sampling.SteadyState:
+		cfg.logger.Printf("Processing with Adaptive Sampling + Steady State Suppression (interval=%d, context=%d/%d)",
+			cfg.sampling.Interval, cfg.sampling.ContextBefore, cfg.sampling.ContextAfter)
+	case cfg.sampling != nil:
+		cfg.logger.Printf("Processing with Adaptive Sampling (interval=%d, context=%d/%d)",
+			cfg.sampling.Interval, cfg.sampling.ContextBefore, cfg.sampling.ContextAfter)
+	default:
+		cfg.logger.Printf("Processing with no sampling")
+	}
 }
 
 func buildResult(inputBytes, outputBytes int64, eventCount int) *Result {
@@ -236,10 +738,89 @@ func logResult(l Logger, r *Result, inPath, outPath string) {
 	if outPath != "" && outPath != "-" {
 		dst = outPath
 	}
-	l.Printf("%s: %s -> %s: %s (%.1f%% reduction, %d events)",
-		src, humanBytes(r.InputBytes),
+	in := humanBytes(r.InputBytes)
+	if r.InputCompression != "" && r.InputCompression != "none" {
+		in = fmt.Sprintf("%s (%s %s)", in, humanBytes(r.CompressedInputBytes), r.InputCompression)
+	}
+	extra := ""
+	if len(r.SkippedRecords) > 0 {
+		extra += fmt.Sprintf(", %d skipped", len(r.SkippedRecords))
+	}
+	if r.ReorderedEvents > 0 {
+		extra += fmt.Sprintf(", %d reordered", r.ReorderedEvents)
+	}
+	if r.LateEvents > 0 {
+		extra += fmt.Sprintf(", %d late", r.LateEvents)
+	}
+	l.Printf("%s: %s -> %s: %s (%.1f%% reduction, %d events, ~%s -> ~%s tokens%s)",
+		src, in,
 		dst, humanBytes(r.OutputBytes),
-		r.Reduction*100, r.EventCount)
+		r.Reduction*100, r.EventCount,
+		humanCount(r.InputTokens), humanCount(r.OutputTokens), extra)
+	if b := r.Budget; b != nil {
+		chosen := "no sampling"
+		if b.Sampling != nil {
+			chosen = fmt.Sprintf("interval=%d, context=%d/%d", b.Sampling.Interval, b.Sampling.ContextBefore, b.Sampling.ContextAfter)
+		}
+		fit := "fits"
+		if !b.Fits {
+			fit = "does not fit, using coarsest settings"
+		}
+		l.Printf("token budget %s: %s (%s, %d passes)", humanCount(b.Tokens), chosen, fit, b.Trials)
+	}
+	if len(r.Chunks) > 0 {
+		largest := 0
+		for _, c := range r.Chunks {
+			if c.Tokens > largest {
+				largest = c.Tokens
+			}
+		}
+		l.Printf("%d chunks (largest ~%s tokens)", len(r.Chunks), humanCount(largest))
+	}
+	if len(r.TokensByEvent) > 0 {
+		l.Printf("tokens by event: %s", topTokens(r.TokensByEvent, 5))
+	}
+	if len(r.TokensByCategory) > 0 {
+		l.Printf("tokens by getstats category: %s", topTokens(r.TokensByCategory, 5))
+	}
+}
+
+// topTokens formats the n largest entries of counts as "name 1.2k (40%), ...".
+func topTokens(counts map[string]int, n int) string {
+	names := make([]string, 0, len(counts))
+	total := 0
+	for name, c := range counts {
+		names = append(names, name)
+		total += c
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	parts := make([]string, 0, n+1)
+	for i, name := range names {
+		if i == n {
+			parts = append(parts, fmt.Sprintf("+%d more", len(names)-n))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s %s (%.0f%%)", name, humanCount(counts[name]), 100*float64(counts[name])/float64(total)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// humanCount formats a count as 950, 12.3k or 1.2M.
+func humanCount(n int) string {
+	switch {
+	case n >= 1000000:
+		return fmt.Sprintf("%.1fM", float64(n)/1000000)
+	case n >= 1000:
+		return fmt.Sprintf("%.1fk", float64(n)/1000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
 }
 
 func humanBytes(b int64) string {
@@ -0,0 +1,102 @@
+package rtcstats_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"rtcstats"
+)
+
+// object writes a JSON object with the given key/value pairs, in reverse
+// order if reverse is set.
+func object(reverse bool, kv ...string) string {
+	pairs := make([]string, 0, len(kv)/2)
+	for i := 0; i < len(kv); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%q: %s", kv[i], kv[i+1]))
+	}
+	if reverse {
+		for i, j := 0, len(pairs)-1; i < j; i, j = i+1, j-1 {
+			pairs[i], pairs[j] = pairs[j], pairs[i]
+		}
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// call builds a dump with several entries in each multi-entry category,
+// writing every object's keys in reverse order if reverse is set.
+func call(reverse bool) []byte {
+	var b strings.Builder
+	ts := int64(1700000000000)
+	line := func(name, scope, payload string) {
+		fmt.Fprintf(&b, "[%q, %q, %s, %d]\n", name, scope, payload, ts)
+		ts += 250
+	}
+	line("create", "0-pub", object(reverse,
+		"iceServers", `[]`, "bundlePolicy", `"max-bundle"`, "sdpSemantics", `"unified-plan"`, "iceTransportPolicy", `"all"`))
+	for i := 0; i < 4; i++ {
+		line("onicecandidate", "0-pub", object(reverse,
+			"candidate", fmt.Sprintf(`"candidate:%d 1 udp 2122260223 10.0.0.%d 5000%d typ host"`, i, i, i),
+			"sdpMid", `"0"`, "sdpMLineIndex", `0`))
+	}
+	line("connectionstatechange", "0-pub", `"connected"`)
+
+	for i := 1; i <= 40; i++ {
+		n := func(scale int) string { return fmt.Sprint(scale * i) }
+		var entries []string
+		// Entries of a category differ, so a swap shows in the output
+		for k, rid := range []string{"q", "h", "f"} {
+			entries = append(entries, "ov_"+rid, object(reverse,
+				"bytesSent", n(1000<<k), "framesEncoded", n(30), "qpSum", n(700+k), "framesPerSecond", `30`))
+		}
+		entries = append(entries, "oa", object(reverse, "bytesSent", n(400), "headerBytesSent", n(40), "packetsSent", n(5)))
+		for k, id := range []string{"ri_v", "ri_a"} {
+			entries = append(entries, id, object(reverse,
+				"roundTripTime", fmt.Sprint(0.02+float64(i%3+k)/100), "packetsReceived", n(50+k), "totalRoundTripTime", n(1)))
+		}
+		for k, id := range []string{"cp_1", "cp_2"} {
+			entries = append(entries, id, object(reverse,
+				"bytesSent", n(1500*(k+1)), "bytesReceived", n(900), "currentRoundTripTime", `0.02`, "responsesReceived", n(1)))
+		}
+		line("getstats", "0-pub", object(reverse, entries...))
+		if i == 20 {
+			line("connectionstatechange", "0-pub", `"disconnected"`)
+			line("connectionstatechange", "0-pub", `"connected"`)
+		}
+	}
+	return []byte(b.String())
+}
+
+func TestProcessDeterministic(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []rtcstats.Option
+	}{
+		{"plain", nil},
+		{"sampled", []rtcstats.Option{rtcstats.WithSampling()}},
+		{"tabular", []rtcstats.Option{rtcstats.WithTabularStats()}},
+		{"ice aggregation and collapsing", []rtcstats.Option{rtcstats.WithICEAggregation(), rtcstats.WithCollapsing()}},
+		{"chunked", []rtcstats.Option{rtcstats.WithChunking(500), rtcstats.WithLegend()}},
+		{"correlated compact", []rtcstats.Option{rtcstats.WithCorrelation(), rtcstats.WithOutputFormat(rtcstats.FormatCompact)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, _, err := rtcstats.ProcessBytes(call(false), tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Map iteration order changes from run to run, so repeat enough
+			// times to hit a different one
+			for run := 0; run < 20; run++ {
+				got, _, err := rtcstats.ProcessBytes(call(run%2 == 1), tt.opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Fatalf("run %d (reversed keys: %v) differs:\n%s\nwant:\n%s", run, run%2 == 1, got, want)
+				}
+			}
+		})
+	}
+}